@@ -2,13 +2,17 @@ package ast
 
 import (
 	"bytes"
+	"fmt"
+	"math/big"
 	"monkey/token"
 	"strings"
+	"unicode"
 )
 
 type Node interface {
 	TokenLiteral() string // 返回值是与其管理的词法单元的字面量 主要是为了方便测试
 	String() string       // 方便打印和比较AST节点
+	Pos() token.Position  // 节点词法单元在源码中的位置 中缀表达式是运算符的位置 用于错误信息
 }
 
 // 声明 语句
@@ -25,7 +29,8 @@ type Expression interface {
 
 // 程序 也是根
 type Program struct {
-	Statements []Statement // 切片
+	Statements []Statement   // 切片
+	Comments   []token.Token // 源码中的注释 求值时不用 留给格式化和文档工具
 }
 
 func (p *Program) TokenLiteral() string {
@@ -36,6 +41,13 @@ func (p *Program) TokenLiteral() string {
 	}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer // 缓冲区
 	for _, s := range p.Statements {
@@ -55,6 +67,9 @@ func (ls *LetStatement) statementNode() {}
 func (ls *LetStatement) TokenLiteral() string {
 	return ls.Token.Literal
 }
+func (ls *LetStatement) Pos() token.Position {
+	return ls.Token.Pos
+}
 
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
@@ -77,6 +92,9 @@ func (i *Identifier) expressionNode() {}
 func (i *Identifier) TokenLiteral() string {
 	return i.Token.Literal
 }
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
 
 func (i *Identifier) String() string {
 	return i.Value
@@ -93,6 +111,9 @@ func (rs *ReturnStatement) statementNode() {}
 func (rs *ReturnStatement) TokenLiteral() string {
 	return rs.Token.Literal
 }
+func (rs *ReturnStatement) Pos() token.Position {
+	return rs.Token.Pos
+}
 
 func (rs *ReturnStatement) String() string {
 	var out bytes.Buffer
@@ -104,6 +125,140 @@ func (rs *ReturnStatement) String() string {
 	return out.String()
 }
 
+// while循环 while (x < 10) { x += 1 }
+// 循环是语句 没有值
+type WhileStatement struct {
+	Token     token.Token // while 词法单元
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) statementNode() {}
+func (ws *WhileStatement) TokenLiteral() string {
+	return ws.Token.Literal
+}
+func (ws *WhileStatement) Pos() token.Position {
+	return ws.Token.Pos
+}
+func (ws *WhileStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("while")
+	out.WriteString(ws.Condition.String())
+	out.WriteString(" ")
+	out.WriteString(ws.Body.String())
+	return out.String()
+}
+
+// for-in循环 依次把数组的元素 hash的键 字符串的字符绑定到Variable
+// for (x in [1, 2, 3]) { puts(x) }
+type ForStatement struct {
+	Token    token.Token // for 词法单元
+	Variable *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) statementNode() {}
+func (fs *ForStatement) TokenLiteral() string {
+	return fs.Token.Literal
+}
+func (fs *ForStatement) Pos() token.Position {
+	return fs.Token.Pos
+}
+func (fs *ForStatement) String() string {
+	var out bytes.Buffer
+	out.WriteString("for (")
+	out.WriteString(fs.Variable.String())
+	out.WriteString(" in ")
+	out.WriteString(fs.Iterable.String())
+	out.WriteString(") ")
+	out.WriteString(fs.Body.String())
+	return out.String()
+}
+
+// throw 抛出异常 交给最近的try处理 没有try时程序出错结束
+type ThrowStatement struct {
+	Token token.Token // throw 词法单元
+	Value Expression
+}
+
+func (ts *ThrowStatement) statementNode() {}
+func (ts *ThrowStatement) TokenLiteral() string {
+	return ts.Token.Literal
+}
+func (ts *ThrowStatement) Pos() token.Position {
+	return ts.Token.Pos
+}
+func (ts *ThrowStatement) String() string {
+	return "throw " + ts.Value.String() + ";"
+}
+
+// try { } catch (e) { } finally { }
+// catch和finally至少有一个 表达式的值是try块的值 出错时是catch块的值
+type TryExpression struct {
+	Token     token.Token     // try 词法单元
+	Block     *BlockStatement // try块
+	Parameter *Identifier     // catch绑定的异常 没有catch时为nil
+	Catch     *BlockStatement
+	Finally   *BlockStatement // 总是执行 值被丢弃
+}
+
+func (te *TryExpression) expressionNode() {}
+func (te *TryExpression) TokenLiteral() string {
+	return te.Token.Literal
+}
+func (te *TryExpression) Pos() token.Position {
+	return te.Token.Pos
+}
+func (te *TryExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("try ")
+	out.WriteString(te.Block.String())
+	if te.Catch != nil {
+		out.WriteString(" catch (")
+		out.WriteString(te.Parameter.String())
+		out.WriteString(") ")
+		out.WriteString(te.Catch.String())
+	}
+	if te.Finally != nil {
+		out.WriteString(" finally ")
+		out.WriteString(te.Finally.String())
+	}
+	return out.String()
+}
+
+// break 跳出最内层的循环
+type BreakStatement struct {
+	Token token.Token // break 词法单元
+}
+
+func (bs *BreakStatement) statementNode() {}
+func (bs *BreakStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BreakStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+func (bs *BreakStatement) String() string {
+	return "break;"
+}
+
+// continue 跳过本次循环剩下的语句 开始下一次循环
+type ContinueStatement struct {
+	Token token.Token // continue 词法单元
+}
+
+func (cs *ContinueStatement) statementNode() {}
+func (cs *ContinueStatement) TokenLiteral() string {
+	return cs.Token.Literal
+}
+func (cs *ContinueStatement) Pos() token.Position {
+	return cs.Token.Pos
+}
+func (cs *ContinueStatement) String() string {
+	return "continue;"
+}
+
 // ExpressionStatement 表达式语句 不是真正的语句 是仅由表达式构成的语句 一层封装
 type ExpressionStatement struct {
 	Token      token.Token
@@ -115,6 +270,9 @@ func (es *ExpressionStatement) statementNode() {}
 func (es *ExpressionStatement) TokenLiteral() string {
 	return es.Token.Literal
 }
+func (es *ExpressionStatement) Pos() token.Position {
+	return es.Token.Pos
+}
 
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
@@ -133,10 +291,47 @@ func (il *IntegerLiteral) expressionNode() {}
 func (il *IntegerLiteral) TokenLiteral() string {
 	return il.Token.Literal
 }
+func (il *IntegerLiteral) Pos() token.Position {
+	return il.Token.Pos
+}
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// 超出int64范围的整数字面量 9223372036854775808
+type BigIntLiteral struct {
+	Token token.Token
+	Value *big.Int
+}
+
+func (bl *BigIntLiteral) expressionNode() {}
+func (bl *BigIntLiteral) TokenLiteral() string {
+	return bl.Token.Literal
+}
+func (bl *BigIntLiteral) Pos() token.Position {
+	return bl.Token.Pos
+}
+func (bl *BigIntLiteral) String() string {
+	return bl.Token.Literal
+}
+
+// 浮点数字面量 3.14 1e-9
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) expressionNode() {}
+func (fl *FloatLiteral) TokenLiteral() string {
+	return fl.Token.Literal
+}
+func (fl *FloatLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
 // 前缀表达式
 type PrefixExpression struct {
 	Token    token.Token // 前缀词法单元 ! -
@@ -148,6 +343,9 @@ func (pe *PrefixExpression) expressionNode() {}
 func (pe *PrefixExpression) TokenLiteral() string {
 	return pe.Token.Literal
 }
+func (pe *PrefixExpression) Pos() token.Position {
+	return pe.Token.Pos
+}
 func (pe *PrefixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -169,6 +367,9 @@ func (ie *InfixExpression) expressionNode() {}
 func (ie *InfixExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *InfixExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
 func (ie *InfixExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("(")
@@ -179,6 +380,32 @@ func (ie *InfixExpression) String() string {
 	return out.String() // 5+5 => (5 + 5)
 }
 
+// 赋值表达式 x = 1 arr[0] = 1 x += 1
+// 给已有的绑定或者数组 hash的元素赋值 表达式的值就是赋的值
+type AssignExpression struct {
+	Token    token.Token // = += -= *= /= 词法单元
+	Target   Expression  // 标识符或者索引表达式
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) expressionNode() {}
+func (ae *AssignExpression) TokenLiteral() string {
+	return ae.Token.Literal
+}
+func (ae *AssignExpression) Pos() token.Position {
+	return ae.Token.Pos
+}
+func (ae *AssignExpression) String() string {
+	var out bytes.Buffer
+	out.WriteString("(")
+	out.WriteString(ae.Target.String())
+	out.WriteString(" " + ae.Operator + " ")
+	out.WriteString(ae.Value.String())
+	out.WriteString(")")
+	return out.String() // x+=1 => (x += 1)
+}
+
 // 布尔类型
 type Boolean struct {
 	Token token.Token
@@ -189,6 +416,9 @@ func (b *Boolean) expressionNode() {}
 func (b *Boolean) TokenLiteral() string {
 	return b.Token.Literal
 }
+func (b *Boolean) Pos() token.Position {
+	return b.Token.Pos
+}
 func (b *Boolean) String() string {
 	return b.Token.Literal
 }
@@ -203,6 +433,9 @@ func (bs *BlockStatement) expressionNode() {}
 func (bs *BlockStatement) TokenLiteral() string {
 	return bs.Token.Literal
 }
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 	for _, s := range bs.Statements {
@@ -223,6 +456,9 @@ func (ie *IfExpression) expressionNode() {}
 func (ie *IfExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *IfExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
 func (ie *IfExpression) String() string {
 	var out bytes.Buffer
 	out.WriteString("if")
@@ -242,12 +478,16 @@ type FunctionLiteral struct {
 	Token      token.Token     // fn 词法单元
 	Parameters []*Identifier   // 参数列表
 	Body       *BlockStatement // 函数体
+	Name       string          // 通过let绑定时的名称 编译器用于支持递归调用自身
 }
 
 func (fl *FunctionLiteral) expressionNode() {}
 func (fl *FunctionLiteral) TokenLiteral() string {
 	return fl.Token.Literal
 }
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
 func (fl *FunctionLiteral) String() string {
 	var out bytes.Buffer
 	params := []string{}
@@ -273,6 +513,9 @@ func (ce *CallExpression) expressionNode() {}
 func (ce *CallExpression) TokenLiteral() string {
 	return ce.Token.Literal
 }
+func (ce *CallExpression) Pos() token.Position {
+	return ce.Token.Pos
+}
 
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
@@ -297,9 +540,43 @@ func (sl *StringLiteral) expressionNode() {}
 func (sl *StringLiteral) TokenLiteral() string {
 	return sl.Token.Literal
 }
+func (sl *StringLiteral) Pos() token.Position {
+	return sl.Token.Pos
+}
 
+// 重新转义 输出的源码再次解析后得到相同的字符串
 func (sl *StringLiteral) String() string {
-	return sl.Token.Literal
+	return QuoteString(sl.Value)
+}
+
+// 字符串加上双引号 特殊字符转义为词法分析器可以识别的形式
+func QuoteString(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			out.WriteString(`\"`)
+		case '\\':
+			out.WriteString(`\\`)
+		case '\n':
+			out.WriteString(`\n`)
+		case '\t':
+			out.WriteString(`\t`)
+		case '\r':
+			out.WriteString(`\r`)
+		case 0:
+			out.WriteString(`\0`)
+		default:
+			if unicode.IsPrint(r) {
+				out.WriteRune(r)
+			} else { // 其他控制字符和不可见字符
+				fmt.Fprintf(&out, `\u{%X}`, r)
+			}
+		}
+	}
+	out.WriteByte('"')
+	return out.String()
 }
 
 // 数组字面量
@@ -312,6 +589,9 @@ func (al *ArrayLiteral) expressionNode() {}
 func (al *ArrayLiteral) TokenLiteral() string {
 	return al.Token.Literal
 }
+func (al *ArrayLiteral) Pos() token.Position {
+	return al.Token.Pos
+}
 
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
@@ -336,6 +616,9 @@ func (ie *IndexExpression) expressionNode() {}
 func (ie *IndexExpression) TokenLiteral() string {
 	return ie.Token.Literal
 }
+func (ie *IndexExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
 
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
@@ -357,6 +640,9 @@ func (hl *HashLiteral) expressionNode() {}
 func (hl *HashLiteral) TokenLiteral() string {
 	return hl.Token.Literal
 }
+func (hl *HashLiteral) Pos() token.Position {
+	return hl.Token.Pos
+}
 
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
@@ -381,6 +667,9 @@ func (ml *MacroLiteral) expressionNode() {}
 func (ml *MacroLiteral) TokenLiteral() string {
 	return ml.Token.Literal
 }
+func (ml *MacroLiteral) Pos() token.Position {
+	return ml.Token.Pos
+}
 
 func (ml *MacroLiteral) String() string {
 	var out bytes.Buffer
@@ -395,3 +684,20 @@ func (ml *MacroLiteral) String() string {
 	out.WriteString(ml.Body.String())
 	return out.String()
 }
+
+// 语法错误的占位语句 错误恢复时代替出错的语句 出错的AST仍然可以完整遍历
+type BadStatement struct {
+	Token token.Token // 出错语句的第一个词法单元
+}
+
+func (bs *BadStatement) statementNode() {}
+func (bs *BadStatement) TokenLiteral() string {
+	return bs.Token.Literal
+}
+func (bs *BadStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+
+func (bs *BadStatement) String() string {
+	return "<bad statement>"
+}
@@ -33,3 +33,24 @@ func TestString(t *testing.T) {
 		t.Errorf("program.String() wrong. got=%q", program.String())
 	}
 }
+
+func TestStringLiteralString(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"hello", `"hello"`},
+		{"a\nb\tc\rd", `"a\nb\tc\rd"`},
+		{`say "hi" \o/`, `"say \"hi\" \\o/"`},
+		{"nul\x00", `"nul\0"`},
+		{"bell\a", `"bell\u{7}"`},
+		{"中文😀", `"中文😀"`},
+	}
+
+	for _, tt := range tests {
+		lit := &StringLiteral{Token: token.Token{Type: token.STRING, Literal: tt.value}, Value: tt.value}
+		if lit.String() != tt.expected {
+			t.Errorf("String() wrong. want=%s, got=%s", tt.expected, lit.String())
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package ast
+
+// 深度优先遍历ast 先访问节点本身再访问子节点 f返回false时不再访问该节点的子节点
+// 和Modify不同 只读取不修改 会访问所有的子节点 包括函数调用的参数
+func Inspect(node Node, f func(Node) bool) {
+	if node == nil || !f(node) { // 解析出错时子节点可能是nil
+		return
+	}
+	switch node := node.(type) {
+	case *Program:
+		for _, s := range node.Statements {
+			Inspect(s, f)
+		}
+	case *ExpressionStatement:
+		Inspect(node.Expression, f)
+	case *LetStatement:
+		Inspect(node.Name, f)
+		Inspect(node.Value, f)
+	case *ReturnStatement:
+		Inspect(node.ReturnValue, f)
+	case *WhileStatement:
+		Inspect(node.Condition, f)
+		Inspect(node.Body, f)
+	case *ForStatement:
+		Inspect(node.Variable, f)
+		Inspect(node.Iterable, f)
+		Inspect(node.Body, f)
+	case *ThrowStatement:
+		Inspect(node.Value, f)
+	case *BlockStatement:
+		for _, s := range node.Statements {
+			Inspect(s, f)
+		}
+	case *PrefixExpression:
+		Inspect(node.Right, f)
+	case *InfixExpression:
+		Inspect(node.Left, f)
+		Inspect(node.Right, f)
+	case *AssignExpression:
+		Inspect(node.Target, f)
+		Inspect(node.Value, f)
+	case *IndexExpression:
+		Inspect(node.Left, f)
+		Inspect(node.Index, f)
+	case *IfExpression:
+		Inspect(node.Condition, f)
+		Inspect(node.Consequence, f)
+		if node.Alternative != nil {
+			Inspect(node.Alternative, f)
+		}
+	case *TryExpression:
+		Inspect(node.Block, f)
+		if node.Catch != nil {
+			Inspect(node.Parameter, f)
+			Inspect(node.Catch, f)
+		}
+		if node.Finally != nil {
+			Inspect(node.Finally, f)
+		}
+	case *FunctionLiteral:
+		for _, p := range node.Parameters {
+			Inspect(p, f)
+		}
+		Inspect(node.Body, f)
+	case *MacroLiteral:
+		for _, p := range node.Parameters {
+			Inspect(p, f)
+		}
+		Inspect(node.Body, f)
+	case *CallExpression:
+		Inspect(node.Function, f)
+		for _, a := range node.Arguments {
+			Inspect(a, f)
+		}
+	case *ArrayLiteral:
+		for _, el := range node.Elements {
+			Inspect(el, f)
+		}
+	case *HashLiteral:
+		for k, v := range node.Pairs {
+			Inspect(k, f)
+			Inspect(v, f)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package ast
+
+import "testing"
+
+func TestInspect(t *testing.T) {
+	// let f = fn(x) { g(x = 1, [2]) };
+	program := &Program{
+		Statements: []Statement{
+			&LetStatement{
+				Name: &Identifier{Value: "f"},
+				Value: &FunctionLiteral{
+					Parameters: []*Identifier{{Value: "x"}},
+					Body: &BlockStatement{
+						Statements: []Statement{
+							&ExpressionStatement{Expression: &CallExpression{
+								Function: &Identifier{Value: "g"},
+								Arguments: []Expression{
+									&AssignExpression{Target: &Identifier{Value: "x"}, Operator: "=", Value: &IntegerLiteral{Value: 1}},
+									&ArrayLiteral{Elements: []Expression{&IntegerLiteral{Value: 2}}},
+								},
+							}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	var identifiers []string
+	var sum int64
+	Inspect(program, func(node Node) bool {
+		switch node := node.(type) {
+		case *Identifier:
+			identifiers = append(identifiers, node.Value)
+		case *IntegerLiteral:
+			sum += node.Value
+		}
+		return true
+	})
+	expected := []string{"f", "x", "g", "x"}
+	if len(identifiers) != len(expected) {
+		t.Fatalf("expected identifiers %v, got %v", expected, identifiers)
+	}
+	for i, name := range expected {
+		if identifiers[i] != name {
+			t.Errorf("identifiers[%d] - expected %s, got %s", i, name, identifiers[i])
+		}
+	}
+	if sum != 3 {
+		t.Errorf("expected to visit all integer literals, sum=%d", sum)
+	}
+
+	// 返回false时跳过子节点
+	visited := 0
+	Inspect(program, func(node Node) bool {
+		visited++
+		_, isFunction := node.(*FunctionLiteral)
+		return !isFunction
+	})
+	if visited != 4 { // Program LetStatement Identifier FunctionLiteral
+		t.Errorf("expected 4 visited nodes, got %d", visited)
+	}
+}
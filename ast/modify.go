@@ -18,6 +18,9 @@ func Modify(node Node, modifier ModifierFunc) Node {
 	case *InfixExpression:
 		node.Left, _ = Modify(node.Left, modifier).(Expression)
 		node.Right, _ = Modify(node.Right, modifier).(Expression)
+	case *AssignExpression:
+		node.Target, _ = Modify(node.Target, modifier).(Expression)
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
 	case *PrefixExpression:
 		node.Right, _ = Modify(node.Right, modifier).(Expression)
 	case *IndexExpression:
@@ -29,12 +32,28 @@ func Modify(node Node, modifier ModifierFunc) Node {
 		if node.Alternative != nil {
 			node.Alternative, _ = Modify(node.Alternative, modifier).(*BlockStatement)
 		}
+	case *TryExpression:
+		node.Block, _ = Modify(node.Block, modifier).(*BlockStatement)
+		if node.Catch != nil {
+			node.Catch, _ = Modify(node.Catch, modifier).(*BlockStatement)
+		}
+		if node.Finally != nil {
+			node.Finally, _ = Modify(node.Finally, modifier).(*BlockStatement)
+		}
 	case *BlockStatement:
 		for i := range node.Statements {
 			node.Statements[i], _ = Modify(node.Statements[i], modifier).(Statement)
 		}
 	case *ReturnStatement:
 		node.ReturnValue, _ = Modify(node.ReturnValue, modifier).(Expression)
+	case *ThrowStatement:
+		node.Value, _ = Modify(node.Value, modifier).(Expression)
+	case *WhileStatement:
+		node.Condition, _ = Modify(node.Condition, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
+	case *ForStatement:
+		node.Iterable, _ = Modify(node.Iterable, modifier).(Expression)
+		node.Body, _ = Modify(node.Body, modifier).(*BlockStatement)
 	case *LetStatement:
 		node.Value = Modify(node.Value, modifier).(Expression)
 	case *FunctionLiteral:
@@ -0,0 +1,235 @@
+// 字节码 指令集定义 编译器生成 虚拟机执行
+package code
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// 指令序列 由操作码和操作数组成的字节切片
+type Instructions []byte
+
+// 反汇编 方便调试和测试
+func (ins Instructions) String() string {
+	var out bytes.Buffer
+	i := 0
+	for i < len(ins) {
+		def, err := Lookup(ins[i])
+		if err != nil {
+			fmt.Fprintf(&out, "ERROR: %s\n", err)
+			i++
+			continue
+		}
+		operands, read := ReadOperands(def, ins[i+1:])
+		fmt.Fprintf(&out, "%04d %s\n", i, ins.fmtInstruction(def, operands))
+		i += 1 + read
+	}
+	return out.String()
+}
+
+func (ins Instructions) fmtInstruction(def *Definition, operands []int) string {
+	operandCount := len(def.OperandWidths)
+	if len(operands) != operandCount {
+		return fmt.Sprintf("ERROR: operand len %d does not match defined %d\n", len(operands), operandCount)
+	}
+	switch operandCount {
+	case 0:
+		return def.Name
+	case 1:
+		return fmt.Sprintf("%s %d", def.Name, operands[0])
+	case 2:
+		return fmt.Sprintf("%s %d %d", def.Name, operands[0], operands[1])
+	}
+	return fmt.Sprintf("ERROR: unhandled operandCount for %s\n", def.Name)
+}
+
+// 操作码 一个字节
+type Opcode byte
+
+const (
+	OpConstant          Opcode = iota // 从常量池加载常量 操作数是常量索引
+	OpPop                             // 弹出栈顶元素 表达式语句结束时使用
+	OpAdd                             // +
+	OpSub                             // -
+	OpMul                             // *
+	OpDiv                             // /
+	OpTrue                            // true
+	OpFalse                           // false
+	OpNull                            // null
+	OpEqual                           // ==
+	OpNotEqual                        // !=
+	OpGreaterThan                     // >
+	OpLessThan                        // < 不交换操作数 保证求值顺序和错误信息与求值器一致
+	OpMinus                           // -x
+	OpBang                            // !x
+	OpJumpNotTruthy                   // 栈顶不是真值时跳转 操作数是跳转的目标位置
+	OpJump                            // 无条件跳转
+	OpGetGlobal                       // 读取全局绑定
+	OpSetGlobal                       // 写入全局绑定
+	OpGetLocal                        // 读取局部绑定
+	OpSetLocal                        // 写入局部绑定
+	OpGetBuiltin                      // 读取内置函数
+	OpGetFree                         // 读取闭包捕获的自由变量
+	OpCurrentClosure                  // 当前正在执行的闭包 递归调用自身时使用
+	OpArray                           // 数组字面量 操作数是元素个数
+	OpHash                            // hash字面量 操作数是键和值的总个数
+	OpIndex                           // 索引表达式
+	OpCall                            // 函数调用 操作数是参数个数
+	OpReturnValue                     // 带返回值返回
+	OpReturn                          // 没有返回值 返回null
+	OpClosure                         // 创建闭包 操作数是函数常量索引和自由变量个数
+	OpBitAnd                          // &
+	OpBitOr                           // |
+	OpBitXor                          // ^
+	OpShiftLeft                       // <<
+	OpShiftRight                      // >>
+	OpBitNot                          // ~ 按位取反
+	OpGreaterEqual                    // >=
+	OpLessEqual                       // <=
+	OpJumpIfFalsyOrPop                // && 栈顶为假时保留栈顶并跳转 否则弹出栈顶
+	OpJumpIfTruthyOrPop               // || 栈顶为真时保留栈顶并跳转 否则弹出栈顶
+	OpSetIndex                        // 给数组或hash的元素赋值 弹出容器 索引和值 压入值
+	OpDupTwo                          // 复制栈顶的两个元素 复合赋值 a[i] += 1 中取值和赋值共用a和i
+	OpAssignLocal                     // 给已有的局部绑定赋值 被捕获的绑定通过cell写入
+	OpAssignFree                      // 给闭包捕获的自由变量赋值
+	OpCaptureLocal                    // 闭包捕获会被赋值的局部绑定 把绑定放入cell共享 压入cell
+	OpCaptureFree                     // 闭包捕获外层闭包中会被赋值的自由变量 压入cell本身
+	OpIter                            // 弹出for-in循环的对象 压入迭代器 迭代器在循环期间留在栈上
+	OpIterNext                        // 压入迭代器的下一个值 没有了就弹出迭代器并跳转 操作数是循环结束的位置
+	OpTry                             // 开始try块 记录出错时跳转的位置(catch或者finally)和当前的栈
+	OpEndTry                          // try块正常结束 不再捕获错误
+	OpThrow                           // 弹出栈顶的值作为错误抛出
+	OpCatch                           // 把栈顶捕获的错误转换成catch绑定的hash
+	OpEndFinally                      // finally块结束 弹出栈顶 是错误时继续抛出
+	OpMod                             // %
+	OpPow                             // **
+	OpIn                              // in
+	OpAssignGlobal                    // 给已有的全局绑定赋值 绑定还没有定义时抛出NameError
+)
+
+// 操作码定义 可读的名称和每个操作数占用的字节数
+type Definition struct {
+	Name          string
+	OperandWidths []int
+}
+
+var definitions = map[Opcode]*Definition{
+	OpConstant:          {"OpConstant", []int{2}},
+	OpPop:               {"OpPop", []int{}},
+	OpAdd:               {"OpAdd", []int{}},
+	OpSub:               {"OpSub", []int{}},
+	OpMul:               {"OpMul", []int{}},
+	OpDiv:               {"OpDiv", []int{}},
+	OpTrue:              {"OpTrue", []int{}},
+	OpFalse:             {"OpFalse", []int{}},
+	OpNull:              {"OpNull", []int{}},
+	OpEqual:             {"OpEqual", []int{}},
+	OpNotEqual:          {"OpNotEqual", []int{}},
+	OpGreaterThan:       {"OpGreaterThan", []int{}},
+	OpLessThan:          {"OpLessThan", []int{}},
+	OpMinus:             {"OpMinus", []int{}},
+	OpBang:              {"OpBang", []int{}},
+	OpJumpNotTruthy:     {"OpJumpNotTruthy", []int{2}},
+	OpJump:              {"OpJump", []int{2}},
+	OpGetGlobal:         {"OpGetGlobal", []int{2}},
+	OpSetGlobal:         {"OpSetGlobal", []int{2}},
+	OpGetLocal:          {"OpGetLocal", []int{1}},
+	OpSetLocal:          {"OpSetLocal", []int{1}},
+	OpGetBuiltin:        {"OpGetBuiltin", []int{1}},
+	OpGetFree:           {"OpGetFree", []int{1}},
+	OpCurrentClosure:    {"OpCurrentClosure", []int{}},
+	OpArray:             {"OpArray", []int{2}},
+	OpHash:              {"OpHash", []int{2}},
+	OpIndex:             {"OpIndex", []int{}},
+	OpCall:              {"OpCall", []int{1}},
+	OpReturnValue:       {"OpReturnValue", []int{}},
+	OpReturn:            {"OpReturn", []int{}},
+	OpClosure:           {"OpClosure", []int{2, 1}},
+	OpBitAnd:            {"OpBitAnd", []int{}},
+	OpBitOr:             {"OpBitOr", []int{}},
+	OpBitXor:            {"OpBitXor", []int{}},
+	OpShiftLeft:         {"OpShiftLeft", []int{}},
+	OpShiftRight:        {"OpShiftRight", []int{}},
+	OpBitNot:            {"OpBitNot", []int{}},
+	OpGreaterEqual:      {"OpGreaterEqual", []int{}},
+	OpLessEqual:         {"OpLessEqual", []int{}},
+	OpJumpIfFalsyOrPop:  {"OpJumpIfFalsyOrPop", []int{2}},
+	OpJumpIfTruthyOrPop: {"OpJumpIfTruthyOrPop", []int{2}},
+	OpSetIndex:          {"OpSetIndex", []int{}},
+	OpDupTwo:            {"OpDupTwo", []int{}},
+	OpAssignLocal:       {"OpAssignLocal", []int{1}},
+	OpAssignFree:        {"OpAssignFree", []int{1}},
+	OpCaptureLocal:      {"OpCaptureLocal", []int{1}},
+	OpCaptureFree:       {"OpCaptureFree", []int{1}},
+	OpIter:              {"OpIter", []int{}},
+	OpIterNext:          {"OpIterNext", []int{2}},
+	OpTry:               {"OpTry", []int{2}},
+	OpEndTry:            {"OpEndTry", []int{}},
+	OpThrow:             {"OpThrow", []int{}},
+	OpCatch:             {"OpCatch", []int{}},
+	OpEndFinally:        {"OpEndFinally", []int{}},
+	OpMod:               {"OpMod", []int{}},
+	OpPow:               {"OpPow", []int{}},
+	OpIn:                {"OpIn", []int{}},
+	OpAssignGlobal:      {"OpAssignGlobal", []int{2}},
+}
+
+// 查找操作码的定义
+func Lookup(op byte) (*Definition, error) {
+	def, ok := definitions[Opcode(op)]
+	if !ok {
+		return nil, fmt.Errorf("opcode %d undefined", op)
+	}
+	return def, nil
+}
+
+// 生成一条指令 操作数按大端序编码
+func Make(op Opcode, operands ...int) []byte {
+	def, ok := definitions[op]
+	if !ok {
+		return []byte{}
+	}
+	instructionLen := 1
+	for _, w := range def.OperandWidths {
+		instructionLen += w
+	}
+	instruction := make([]byte, instructionLen)
+	instruction[0] = byte(op)
+	offset := 1
+	for i, o := range operands {
+		width := def.OperandWidths[i]
+		switch width {
+		case 2:
+			binary.BigEndian.PutUint16(instruction[offset:], uint16(o))
+		case 1:
+			instruction[offset] = byte(o)
+		}
+		offset += width
+	}
+	return instruction
+}
+
+// 解码操作数 Make的逆操作 返回操作数和读取的字节数
+func ReadOperands(def *Definition, ins Instructions) ([]int, int) {
+	operands := make([]int, len(def.OperandWidths))
+	offset := 0
+	for i, width := range def.OperandWidths {
+		switch width {
+		case 2:
+			operands[i] = int(ReadUint16(ins[offset:]))
+		case 1:
+			operands[i] = int(ReadUint8(ins[offset:]))
+		}
+		offset += width
+	}
+	return operands, offset
+}
+
+func ReadUint16(ins Instructions) uint16 {
+	return binary.BigEndian.Uint16(ins)
+}
+
+func ReadUint8(ins Instructions) uint8 {
+	return uint8(ins[0])
+}
@@ -0,0 +1,869 @@
+// 编译器 把AST编译为字节码指令和常量池 交给虚拟机执行
+package compiler
+
+import (
+	"fmt"
+	"monkey/ast"
+	"monkey/code"
+	"monkey/object"
+	"monkey/token"
+	"sort"
+	"strings"
+)
+
+// 已生成的指令 记录操作码和所在位置
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+// 编译作用域 每个函数体都有自己的指令序列
+type CompilationScope struct {
+	instructions        code.Instructions
+	positions           []token.Position   // 每个字节对应的源码位置
+	lastInstruction     EmittedInstruction // 最后一条指令
+	previousInstruction EmittedInstruction // 倒数第二条指令
+	loops               []*loopContext     // 正在编译的循环 最后一个是最内层的
+	tries               []*tryContext      // 正在编译的try块和catch块 离开它们时要结束try并执行finally
+}
+
+// 正在编译的循环 break生成的跳转在循环结束时回填
+type loopContext struct {
+	start       int   // 循环开始的位置 continue跳转到这里
+	hasIterator bool  // for-in循环的迭代器在栈上 break跳出之前要弹出
+	breakJumps  []int // break生成的跳转指令的位置
+	tries       int   // 循环开始时的try层数 break continue只离开循环中的try
+}
+
+// 正在编译的try块 或者有finally时的catch块 虚拟机中有对应的错误处理
+type tryContext struct {
+	finally *ast.BlockStatement // return break continue离开时要执行的finally 没有时为nil
+}
+
+type Compiler struct {
+	constants   []object.Object // 常量池
+	symbolTable *SymbolTable
+	scopes      []CompilationScope
+	scopeIndex  int
+	pos         token.Position  // 正在编译的节点的位置 生成的指令都记录这个位置
+	rebound     map[string]bool // 程序中会被重新绑定的名称 闭包捕获这些绑定时需要共享存储
+}
+
+// 编译结果 交给虚拟机的内容
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	Positions    []token.Position
+	Globals      map[string]int // 全局绑定的名称和下标 虚拟机用于报告没有赋值的绑定
+}
+
+func New() *Compiler {
+	mainScope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	return &Compiler{
+		constants:   []object.Object{},
+		symbolTable: NewGlobalSymbolTable(),
+		scopes:      []CompilationScope{mainScope},
+		scopeIndex:  0,
+	}
+}
+
+// 保留之前编译的符号表和常量池 repl中多次输入之间共享全局绑定
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	compiler := New()
+	compiler.symbolTable = s
+	compiler.constants = constants
+	return compiler
+}
+
+// 创建一个定义好内置函数的全局符号表
+func NewGlobalSymbolTable() *SymbolTable {
+	symbolTable := NewSymbolTable()
+	for i, v := range object.Builtins {
+		symbolTable.DefineBuiltin(i, v.Name)
+	}
+	return symbolTable
+}
+
+func (c *Compiler) Compile(node ast.Node) error {
+	if node == nil {
+		return nil
+	}
+	// 子节点编译结束后恢复 保证父节点的指令记录的是父节点的位置
+	outerPos := c.pos
+	if pos := node.Pos(); pos.IsValid() {
+		c.pos = pos
+	}
+	defer func() { c.pos = outerPos }()
+	switch node := node.(type) {
+	case *ast.Program:
+		c.rebound = reboundNames(node)
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop) // 表达式语句的值不再使用 弹出
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			if err := c.Compile(s); err != nil {
+				return err
+			}
+		}
+	case *ast.LetStatement:
+		// 先编译值再定义 和求值器一致 let x = x + 1 右侧的x是已有的绑定
+		// 函数递归调用自身通过FunctionScope解析 名称会被重新绑定时函数体中用的是这个绑定 需要先定义
+		if _, ok := node.Value.(*ast.FunctionLiteral); ok && c.rebound[node.Name.Value] {
+			c.symbolTable.Define(node.Name.Value)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.storeDefinition(c.symbolTable.Define(node.Name.Value))
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+	case *ast.BreakStatement:
+		loop := c.currentLoop()
+		if loop == nil {
+			return c.errorf("break outside loop")
+		}
+		if err := c.exitTries(loop.tries); err != nil {
+			return err
+		}
+		if loop.hasIterator {
+			c.emit(code.OpPop)
+		}
+		loop.breakJumps = append(loop.breakJumps, c.emit(code.OpJump, 9999))
+	case *ast.ContinueStatement:
+		loop := c.currentLoop()
+		if loop == nil {
+			return c.errorf("continue outside loop")
+		}
+		if err := c.exitTries(loop.tries); err != nil {
+			return err
+		}
+		c.emit(code.OpJump, loop.start)
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		if err := c.exitTries(0); err != nil { // 返回值留在栈上 finally不改变栈
+			return err
+		}
+		c.emit(code.OpReturnValue)
+	case *ast.ThrowStatement:
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		c.emit(code.OpThrow)
+	case *ast.TryExpression:
+		return c.compileTryExpression(node)
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			symbol = c.unresolvedGlobal(node.Value)
+		}
+		c.loadSymbol(symbol)
+	case *ast.IntegerLiteral:
+		integer := &object.Integer{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.BigIntLiteral:
+		integer := &object.BigInt{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(integer))
+	case *ast.FloatLiteral:
+		float := &object.Float{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(float))
+	case *ast.StringLiteral:
+		str := &object.String{Value: node.Value}
+		c.emit(code.OpConstant, c.addConstant(str))
+	case *ast.Boolean:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+	case *ast.PrefixExpression:
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		switch node.Operator {
+		case "!":
+			c.emit(code.OpBang)
+		case "-":
+			c.emit(code.OpMinus)
+		case "~":
+			c.emit(code.OpBitNot)
+		default:
+			return c.errorf("unknown operator %s", node.Operator)
+		}
+	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" {
+			return c.compileLogicalExpression(node)
+		}
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Right); err != nil {
+			return err
+		}
+		op, ok := infixOpcodes[node.Operator]
+		if !ok {
+			return c.errorf("unknown operator %s", node.Operator)
+		}
+		c.emit(op)
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+	case *ast.ArrayLiteral:
+		for _, el := range node.Elements {
+			if err := c.Compile(el); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpArray, len(node.Elements))
+	case *ast.HashLiteral:
+		// map的遍历顺序不固定 按照源码排序 保证生成的指令稳定
+		keys := []ast.Expression{}
+		for k := range node.Pairs {
+			keys = append(keys, k)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		for _, k := range keys {
+			if err := c.Compile(k); err != nil {
+				return err
+			}
+			if err := c.Compile(node.Pairs[k]); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpHash, len(node.Pairs)*2)
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emit(code.OpIndex)
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+	case *ast.CallExpression:
+		if node.Function.TokenLiteral() == "quote" {
+			return c.compileQuote(node)
+		}
+		if err := c.Compile(node.Function); err != nil {
+			return err
+		}
+		for _, a := range node.Arguments {
+			if err := c.Compile(a); err != nil {
+				return err
+			}
+		}
+		c.emit(code.OpCall, len(node.Arguments))
+	case *ast.MacroLiteral:
+		return c.errorf("macro literal must be bound by a top-level let statement")
+	}
+	return nil
+}
+
+// 中缀运算符对应的操作码
+var infixOpcodes = map[string]code.Opcode{
+	"+":  code.OpAdd,
+	"-":  code.OpSub,
+	"*":  code.OpMul,
+	"/":  code.OpDiv,
+	"%":  code.OpMod,
+	"**": code.OpPow,
+	">":  code.OpGreaterThan,
+	"<":  code.OpLessThan,
+	">=": code.OpGreaterEqual,
+	"<=": code.OpLessEqual,
+	"==": code.OpEqual,
+	"!=": code.OpNotEqual,
+	"&":  code.OpBitAnd,
+	"|":  code.OpBitOr,
+	"^":  code.OpBitXor,
+	"<<": code.OpShiftLeft,
+	">>": code.OpShiftRight,
+	"in": code.OpIn,
+}
+
+// 赋值表达式 赋值之后把新的值压栈作为表达式的值
+// 复合赋值先取出当前的值 a[i] += 1 通过OpDupTwo复用a和i 保证它们只求值一次
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	compound := node.Operator != "="
+	op, ok := infixOpcodes[strings.TrimSuffix(node.Operator, "=")]
+	if compound && !ok {
+		return c.errorf("unknown operator %s", node.Operator)
+	}
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(target.Value)
+		if !ok {
+			symbol = c.unresolvedGlobal(target.Value)
+		}
+		if symbol.Scope == BuiltinScope {
+			return c.errorf("cannot assign to builtin %s", target.Value)
+		}
+		if compound {
+			c.loadSymbol(symbol)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+		c.storeSymbol(symbol)
+		c.loadSymbol(symbol)
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(code.OpDupTwo)
+			c.emit(code.OpIndex)
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+		if compound {
+			c.emit(op)
+		}
+		c.emit(code.OpSetIndex)
+	default:
+		return c.errorf("invalid assignment target %s", node.Target.String())
+	}
+	return nil
+}
+
+// 程序中会被重新绑定的名称 被赋值的 for-in的循环变量 定义在循环体中的 以及定义了不止一次的
+// 和求值器一样 同一个函数中的同名绑定始终是同一个 闭包捕获这些绑定时需要通过cell共享
+// 不区分作用域 同名的绑定都按会被重新绑定处理 只是多用一个cell
+func reboundNames(program *ast.Program) map[string]bool {
+	names := map[string]bool{}
+	defined := map[string]int{}
+	inLoop := map[ast.Node]bool{}
+	ast.Inspect(program, func(node ast.Node) bool {
+		var name string
+		switch node := node.(type) {
+		case *ast.AssignExpression:
+			if ident, ok := node.Target.(*ast.Identifier); ok {
+				names[ident.Value] = true
+			}
+		case *ast.WhileStatement:
+			inLoop[node.Body] = true
+		case *ast.ForStatement:
+			names[node.Variable.Value] = true
+			inLoop[node.Body] = true
+		case *ast.BlockStatement:
+			if inLoop[node] {
+				markLoopDefinitions(node, names)
+			}
+		case *ast.LetStatement:
+			name = node.Name.Value
+		case *ast.TryExpression:
+			if node.Parameter != nil {
+				name = node.Parameter.Value
+			}
+		}
+		if name != "" {
+			defined[name]++
+			if defined[name] > 1 {
+				names[name] = true
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// 循环体中的let和catch每次迭代都会重新绑定
+func markLoopDefinitions(body *ast.BlockStatement, names map[string]bool) {
+	ast.Inspect(body, func(node ast.Node) bool {
+		switch node := node.(type) {
+		case *ast.LetStatement:
+			names[node.Name.Value] = true
+		case *ast.TryExpression:
+			if node.Parameter != nil {
+				names[node.Parameter.Value] = true
+			}
+		}
+		return true
+	})
+}
+
+// 短路求值 左侧的值能决定结果时跳过右侧 栈上留下左侧的值 否则弹出左侧 留下右侧的值
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+	op := code.OpJumpIfFalsyOrPop
+	if node.Operator == "||" {
+		op = code.OpJumpIfTruthyOrPop
+	}
+	jumpPos := c.emit(op, 9999) // 占位 回填
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// while循环 循环是语句 不在栈上留下值
+// 解析器保证break和continue只作为语句出现 跳转时栈上只有for-in循环的迭代器
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	start := len(c.currentInstructions())
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	exitPos := c.emit(code.OpJumpNotTruthy, 9999)
+	if err := c.compileLoopBody(node.Body, start, false); err != nil {
+		return err
+	}
+	c.changeOperand(exitPos, len(c.currentInstructions()))
+	return nil
+}
+
+// for-in循环 迭代器在循环期间留在栈上 访问完之后由OpIterNext弹出
+// 循环变量和let一样定义在当前作用域中
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+	c.emit(code.OpIter)
+	start := len(c.currentInstructions())
+	nextPos := c.emit(code.OpIterNext, 9999)
+	c.storeDefinition(c.symbolTable.Define(node.Variable.Value))
+	if err := c.compileLoopBody(node.Body, start, true); err != nil {
+		return err
+	}
+	c.changeOperand(nextPos, len(c.currentInstructions()))
+	return nil
+}
+
+// 编译循环体 最后跳回循环开始的位置 循环体之后就是循环结束的位置 回填break的跳转
+func (c *Compiler) compileLoopBody(body *ast.BlockStatement, start int, hasIterator bool) error {
+	scope := &c.scopes[c.scopeIndex]
+	loop := &loopContext{start: start, hasIterator: hasIterator, tries: len(scope.tries)}
+	scope.loops = append(scope.loops, loop)
+	if err := c.Compile(body); err != nil {
+		return err
+	}
+	c.emit(code.OpJump, start)
+	scope = &c.scopes[c.scopeIndex] // 编译循环体时可能追加了新的作用域
+	scope.loops = scope.loops[:len(scope.loops)-1]
+	end := len(c.currentInstructions())
+	for _, pos := range loop.breakJumps {
+		c.changeOperand(pos, end)
+	}
+	return nil
+}
+
+// 最内层的循环 函数体中不能跳出外层函数的循环
+func (c *Compiler) currentLoop() *loopContext {
+	loops := c.scopes[c.scopeIndex].loops
+	if len(loops) == 0 {
+		return nil
+	}
+	return loops[len(loops)-1]
+}
+
+// try表达式 try块和catch块都在栈上留下一个值 虚拟机出错时跳转到OpTry记录的位置 栈上是错误对象
+// finally只编译一次 正常结束时栈上是 [值, null] 出错时是 [错误] OpEndFinally弹出栈顶 是错误时继续抛出
+//
+//	OpTry catch; try块; OpEndTry; OpNull; OpJump finally
+//	catch: OpCatch; 绑定e; OpTry finally; catch块; OpEndTry; OpNull; OpJump finally
+//	finally: finally块; OpEndFinally
+func (c *Compiler) compileTryExpression(node *ast.TryExpression) error {
+	jumps := []int{} // 正常结束的跳转 跳到finally或者表达式结束的位置
+	tryPos := c.emit(code.OpTry, 9999)
+	if err := c.compileProtected(node.Block, node.Finally); err != nil {
+		return err
+	}
+	if node.Finally != nil {
+		c.emit(code.OpNull)
+	}
+	jumps = append(jumps, c.emit(code.OpJump, 9999))
+	c.changeOperand(tryPos, len(c.currentInstructions()))
+
+	if node.Catch != nil {
+		c.emit(code.OpCatch)
+		c.storeDefinition(c.symbolTable.Define(node.Parameter.Value))
+		if node.Finally == nil {
+			if err := c.compileBranch(node.Catch); err != nil {
+				return err
+			}
+		} else {
+			tryPos = c.emit(code.OpTry, 9999)
+			if err := c.compileProtected(node.Catch, node.Finally); err != nil {
+				return err
+			}
+			c.emit(code.OpNull)
+			jumps = append(jumps, c.emit(code.OpJump, 9999))
+			c.changeOperand(tryPos, len(c.currentInstructions()))
+		}
+	}
+	// 没有finally时跳到catch块之后 有finally时跳到finally
+	for _, pos := range jumps {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+	if node.Finally == nil {
+		return nil
+	}
+	if err := c.Compile(node.Finally); err != nil {
+		return err
+	}
+	c.emit(code.OpEndFinally)
+	return nil
+}
+
+// 编译有错误处理的块 块的值留在栈上 之后结束try
+func (c *Compiler) compileProtected(block, finally *ast.BlockStatement) error {
+	scope := &c.scopes[c.scopeIndex]
+	scope.tries = append(scope.tries, &tryContext{finally: finally})
+	if err := c.compileBranch(block); err != nil {
+		return err
+	}
+	scope = &c.scopes[c.scopeIndex]
+	scope.tries = scope.tries[:len(scope.tries)-1]
+	c.emit(code.OpEndTry)
+	return nil
+}
+
+// return break continue离开try 从内到外结束第depth层之后的try 并执行它们的finally
+// finally中的let不影响后面的代码 finally中再离开时只需要处理外层的try
+func (c *Compiler) exitTries(depth int) error {
+	scope := &c.scopes[c.scopeIndex]
+	tries := scope.tries
+	defer func() { c.scopes[c.scopeIndex].tries = tries }()
+	for i := len(tries) - 1; i >= depth; i-- {
+		c.emit(code.OpEndTry)
+		if tries[i].finally == nil {
+			continue
+		}
+		c.scopes[c.scopeIndex].tries = tries[:i]
+		store := make(map[string]Symbol, len(c.symbolTable.store))
+		for name, symbol := range c.symbolTable.store {
+			store[name] = symbol
+		}
+		err := c.Compile(tries[i].finally)
+		c.symbolTable.store = store
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// if表达式 条件不成立时跳过结果分支 两个分支都会在栈上留下一个值
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999) // 占位 回填
+	if err := c.compileBranch(node.Consequence); err != nil {
+		return err
+	}
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBranch(node.Alternative); err != nil {
+		return err
+	}
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+// 编译分支 分支的值就是最后一个表达式语句的值
+func (c *Compiler) compileBranch(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpNull) // 空分支或者以let结尾 没有值
+	}
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+	if node.Name != "" && !c.rebound[node.Name] { // 比如函数体中给自己的名称赋值 f = 5 改的是外层的绑定
+		c.symbolTable.DefineFunctionName(node.Name)
+	}
+	for _, p := range node.Parameters {
+		c.symbolTable.define(p.Value)
+	}
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn() // 最后一个表达式的值作为隐式返回值
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.numDefinitions
+	instructions, positions := c.leaveScope()
+	// 把捕获的自由变量依次压栈 由OpClosure收集到闭包中
+	for _, s := range freeSymbols {
+		c.captureSymbol(s)
+	}
+	compiledFn := &object.CompiledFunction{
+		Instructions:  instructions,
+		Positions:     positions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		Name:          node.Name,
+		Source:        (&object.Function{Parameters: node.Parameters, Body: node.Body}).Inspect(),
+	}
+	c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
+	return nil
+}
+
+// quote的参数不求值 作为常量放入常量池 unquote需要在运行时求值 只有求值器支持
+func (c *Compiler) compileQuote(node *ast.CallExpression) error {
+	if len(node.Arguments) != 1 {
+		return c.errorf("wrong number of arguments to quote. got=%d, want=1", len(node.Arguments))
+	}
+	hasUnquote := false
+	ast.Modify(node.Arguments[0], func(n ast.Node) ast.Node {
+		if call, ok := n.(*ast.CallExpression); ok && call.Function.TokenLiteral() == "unquote" {
+			hasUnquote = true
+		}
+		return n
+	})
+	if hasUnquote {
+		return c.errorf("unquote is not supported by the compiler")
+	}
+	quote := &object.Quote{Node: node.Arguments[0]}
+	c.emit(code.OpConstant, c.addConstant(quote))
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emit(code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+// 给刚定义的绑定赋值 弹出栈顶的值 局部绑定已经被捕获时写入共享的cell
+func (c *Compiler) storeDefinition(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+// 没有找到的名称当作之后才定义的全局绑定 比如函数体中引用后面的let定义的函数
+// 和求值器一样在运行时查找 执行到这里时还没有定义就抛出NameError
+func (c *Compiler) unresolvedGlobal(name string) Symbol {
+	table := c.symbolTable
+	for table.Outer != nil {
+		table = table.Outer
+	}
+	return table.Define(name)
+}
+
+// 给已有的绑定赋值 弹出栈顶的值
+func (c *Compiler) storeSymbol(s Symbol) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emit(code.OpAssignGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpAssignLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpAssignFree, s.Index)
+	}
+}
+
+// 创建闭包时压入捕获的绑定 会被重新绑定的绑定压入共享的cell 之后的赋值和定义对定义它的函数和所有闭包都可见
+// 不会被重新绑定的绑定直接复制值 和原来一样
+func (c *Compiler) captureSymbol(s Symbol) {
+	if !c.rebound[s.Name] {
+		c.loadSymbol(s)
+		return
+	}
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, s.Index)
+	default:
+		c.loadSymbol(s)
+	}
+}
+
+// 添加常量 返回常量在常量池中的下标
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+// 生成指令 返回指令的起始位置
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := code.Make(op, operands...)
+	pos := c.addInstruction(ins)
+	c.setLastInstruction(op, pos)
+	return pos
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+	for range ins {
+		c.scopes[c.scopeIndex].positions = append(c.scopes[c.scopeIndex].positions, c.pos)
+	}
+	return posNewInstruction
+}
+
+// 编译错误 以 file:line:col 开头
+func (c *Compiler) errorf(format string, a ...any) error {
+	return fmt.Errorf("%s: %s", c.pos, fmt.Sprintf(format, a...))
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].positions = c.scopes[c.scopeIndex].positions[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+// 回填跳转指令的操作数
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := code.Make(op, operand)
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+// 进入函数体 新的指令序列和符号表
+func (c *Compiler) enterScope() {
+	scope := CompilationScope{
+		instructions:        code.Instructions{},
+		lastInstruction:     EmittedInstruction{},
+		previousInstruction: EmittedInstruction{},
+	}
+	c.scopes = append(c.scopes, scope)
+	c.scopeIndex++
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() (code.Instructions, []token.Position) {
+	instructions := c.currentInstructions()
+	positions := c.scopes[c.scopeIndex].positions
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+	c.symbolTable = c.symbolTable.Outer
+	return instructions, positions
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		Positions:    c.scopes[c.scopeIndex].positions,
+		Globals:      c.globalNames(),
+	}
+}
+
+func (c *Compiler) globalNames() map[string]int {
+	table := c.symbolTable
+	for table.Outer != nil {
+		table = table.Outer
+	}
+	names := map[string]int{}
+	for _, symbol := range table.unset {
+		names[symbol.Name] = symbol.Index
+	}
+	for _, symbol := range table.DefinedSymbols() {
+		names[symbol.Name] = symbol.Index
+	}
+	return names
+}
+
+// 程序最后一条语句是否产生值 虚拟机据此判断栈上的值是否有效
+// 和求值器一样 let语句和循环不产生值 最后一条语句不产生值的块也不产生值 比如 if (true) { let y = 1 }
+func EndsWithValue(program *ast.Program) bool {
+	if len(program.Statements) == 0 {
+		return false
+	}
+	return producesValue(program.Statements[len(program.Statements)-1])
+}
+
+// if的值是执行的分支的值 只要有一个分支产生值就当作产生值 try按没有出错时执行的try块判断
+func producesValue(node ast.Node) bool {
+	switch node := node.(type) {
+	case *ast.LetStatement, *ast.WhileStatement, *ast.ForStatement:
+		return false
+	case *ast.BlockStatement:
+		return len(node.Statements) > 0 && producesValue(node.Statements[len(node.Statements)-1])
+	case *ast.ExpressionStatement:
+		return producesValue(node.Expression)
+	case *ast.IfExpression:
+		return producesValue(node.Consequence) || node.Alternative != nil && producesValue(node.Alternative)
+	case *ast.TryExpression:
+		return producesValue(node.Block)
+	}
+	return true
+}
@@ -0,0 +1,140 @@
+package compiler
+
+import (
+	"monkey/object"
+	"sort"
+)
+
+// 符号作用域
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"     // 闭包捕获的外层局部绑定
+	FunctionScope SymbolScope = "FUNCTION" // 函数自身的名称 用于递归
+)
+
+// 符号 标识符在编译期解析后的信息
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+// 符号表 每个函数体对应一个 通过Outer链接外层
+type SymbolTable struct {
+	Outer          *SymbolTable
+	store          map[string]Symbol
+	numDefinitions int
+	unset          map[string]Symbol // ForgetUnset移出的全局绑定 重新定义时复用原来的下标
+	FreeSymbols    []Symbol          // 当前函数捕获的自由变量 按捕获顺序排列
+}
+
+func NewSymbolTable() *SymbolTable {
+	s := make(map[string]Symbol)
+	free := []Symbol{}
+	return &SymbolTable{store: s, FreeSymbols: free}
+}
+
+// 创建函数作用域的符号表
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// 定义绑定 没有外层的是全局绑定 否则是局部绑定
+// 同一作用域中重复定义的名称复用原来的下标 和求值器一样始终是同一个绑定
+func (s *SymbolTable) Define(name string) Symbol {
+	if existing, ok := s.store[name]; ok && existing.Scope == s.definitionScope() {
+		return existing
+	}
+	if forgotten, ok := s.unset[name]; ok {
+		delete(s.unset, name)
+		s.store[name] = forgotten
+		return forgotten
+	}
+	return s.define(name)
+}
+
+// 总是分配新的下标 函数参数用它定义 同名的参数各占一个位置 后面的遮蔽前面的
+func (s *SymbolTable) define(name string) Symbol {
+	symbol := Symbol{Name: name, Index: s.numDefinitions, Scope: s.definitionScope()}
+	s.store[name] = symbol
+	s.numDefinitions++
+	return symbol
+}
+
+func (s *SymbolTable) definitionScope() SymbolScope {
+	if s.Outer == nil {
+		return GlobalScope
+	}
+	return LocalScope
+}
+
+// 当前符号表中定义的全局或局部绑定 不包括内置函数 按下标排序
+func (s *SymbolTable) DefinedSymbols() []Symbol {
+	symbols := []Symbol{}
+	for _, symbol := range s.store {
+		if symbol.Scope == GlobalScope || symbol.Scope == LocalScope {
+			symbols = append(symbols, symbol)
+		}
+	}
+	sort.Slice(symbols, func(i, j int) bool { return symbols[i].Index < symbols[j].Index })
+	return symbols
+}
+
+// 移除值为nil的全局绑定 repl中出错或者提前返回时 之后的let没有执行 这些名称不算定义过
+// 已经编译的闭包仍然引用原来的下标 所以重新定义时复用它
+func (s *SymbolTable) ForgetUnset(globals []object.Object) {
+	for name, symbol := range s.store {
+		if symbol.Scope == GlobalScope && globals[symbol.Index] == nil {
+			if s.unset == nil {
+				s.unset = map[string]Symbol{}
+			}
+			s.unset[name] = symbol
+			delete(s.store, name)
+		}
+	}
+}
+
+// 定义内置函数 index是内置函数在object.Builtins中的下标
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// 定义函数自身的名称
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// 记录自由变量 返回在当前作用域中对应的符号
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	s.store[original.Name] = symbol
+	return symbol
+}
+
+// 解析标识符 在外层作用域中找到的局部绑定会变成自由变量
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if !ok && s.Outer != nil {
+		obj, ok = s.Outer.Resolve(name)
+		if !ok {
+			return obj, ok
+		}
+		if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+			return obj, ok
+		}
+		free := s.defineFree(obj)
+		return free, true
+	}
+	return obj, ok
+}
@@ -0,0 +1,157 @@
+package compiler
+
+import "testing"
+
+func TestDefine(t *testing.T) {
+	expected := map[string]Symbol{
+		"a": {Name: "a", Scope: GlobalScope, Index: 0},
+		"b": {Name: "b", Scope: GlobalScope, Index: 1},
+		"c": {Name: "c", Scope: LocalScope, Index: 0},
+		"d": {Name: "d", Scope: LocalScope, Index: 1},
+		"e": {Name: "e", Scope: LocalScope, Index: 0},
+		"f": {Name: "f", Scope: LocalScope, Index: 1},
+	}
+
+	global := NewSymbolTable()
+	if a := global.Define("a"); a != expected["a"] {
+		t.Errorf("expected a=%+v, got=%+v", expected["a"], a)
+	}
+	if b := global.Define("b"); b != expected["b"] {
+		t.Errorf("expected b=%+v, got=%+v", expected["b"], b)
+	}
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	if c := firstLocal.Define("c"); c != expected["c"] {
+		t.Errorf("expected c=%+v, got=%+v", expected["c"], c)
+	}
+	if d := firstLocal.Define("d"); d != expected["d"] {
+		t.Errorf("expected d=%+v, got=%+v", expected["d"], d)
+	}
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	if e := secondLocal.Define("e"); e != expected["e"] {
+		t.Errorf("expected e=%+v, got=%+v", expected["e"], e)
+	}
+	if f := secondLocal.Define("f"); f != expected["f"] {
+		t.Errorf("expected f=%+v, got=%+v", expected["f"], f)
+	}
+}
+
+func TestResolveBuiltins(t *testing.T) {
+	global := NewSymbolTable()
+	firstLocal := NewEnclosedSymbolTable(global)
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+
+	expected := []Symbol{
+		{Name: "a", Scope: BuiltinScope, Index: 0},
+		{Name: "c", Scope: BuiltinScope, Index: 1},
+	}
+	for i, v := range expected {
+		global.DefineBuiltin(i, v.Name)
+	}
+
+	for _, table := range []*SymbolTable{global, firstLocal, secondLocal} {
+		for _, sym := range expected {
+			result, ok := table.Resolve(sym.Name)
+			if !ok {
+				t.Errorf("name %s not resolvable", sym.Name)
+				continue
+			}
+			if result != sym {
+				t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+			}
+		}
+	}
+}
+
+func TestResolveFree(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+
+	firstLocal := NewEnclosedSymbolTable(global)
+	firstLocal.Define("c")
+
+	secondLocal := NewEnclosedSymbolTable(firstLocal)
+	secondLocal.Define("e")
+
+	expected := []Symbol{
+		{Name: "a", Scope: GlobalScope, Index: 0},
+		{Name: "c", Scope: FreeScope, Index: 0},
+		{Name: "e", Scope: LocalScope, Index: 0},
+	}
+	for _, sym := range expected {
+		result, ok := secondLocal.Resolve(sym.Name)
+		if !ok {
+			t.Errorf("name %s not resolvable", sym.Name)
+			continue
+		}
+		if result != sym {
+			t.Errorf("expected %s to resolve to %+v, got=%+v", sym.Name, sym, result)
+		}
+	}
+
+	if len(secondLocal.FreeSymbols) != 1 || secondLocal.FreeSymbols[0].Name != "c" {
+		t.Errorf("wrong free symbols. got=%+v", secondLocal.FreeSymbols)
+	}
+
+	if _, ok := secondLocal.Resolve("b"); ok {
+		t.Errorf("name b resolved, but was expected not to")
+	}
+}
+
+func TestDefineAndResolveFunctionName(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineFunctionName("a")
+
+	expected := Symbol{Name: "a", Scope: FunctionScope, Index: 0}
+	result, ok := global.Resolve(expected.Name)
+	if !ok {
+		t.Fatalf("function name %s not resolvable", expected.Name)
+	}
+	if result != expected {
+		t.Errorf("expected %s to resolve to %+v, got=%+v", expected.Name, expected, result)
+	}
+}
+
+func TestDefinedSymbols(t *testing.T) {
+	global := NewSymbolTable()
+	global.DefineBuiltin(0, "len")
+	global.Define("b")
+	global.Define("a")
+	global.Define("b") // 重新定义 复用原来的下标
+
+	expected := []Symbol{
+		{Name: "b", Scope: GlobalScope, Index: 0},
+		{Name: "a", Scope: GlobalScope, Index: 1},
+	}
+	result := global.DefinedSymbols()
+	if len(result) != len(expected) {
+		t.Fatalf("wrong number of symbols. want=%d, got=%d (%+v)", len(expected), len(result), result)
+	}
+	for i, sym := range expected {
+		if result[i] != sym {
+			t.Errorf("symbol %d wrong. want=%+v, got=%+v", i, sym, result[i])
+		}
+	}
+}
+
+func TestRedefine(t *testing.T) {
+	global := NewSymbolTable()
+	a := global.Define("a")
+	global.Define("b")
+	if again := global.Define("a"); again != a {
+		t.Errorf("redefined global wrong. want=%+v, got=%+v", a, again)
+	}
+
+	local := NewEnclosedSymbolTable(global)
+	x := local.define("x")
+	local.define("x") // 同名的参数各占一个位置
+	if again := local.Define("x"); again.Index != 1 || again.Scope != LocalScope {
+		t.Errorf("redefined local wrong. got=%+v (first=%+v)", again, x)
+	}
+	// 外层的绑定被捕获后 在当前作用域中定义同名绑定是新的局部绑定
+	local.Resolve("b")
+	if b := local.Define("b"); b.Scope != LocalScope || b.Index != 2 {
+		t.Errorf("shadowing free symbol wrong. got=%+v", b)
+	}
+}
@@ -0,0 +1,170 @@
+package evaluator
+
+import (
+	"math"
+	"math/big"
+	"monkey/object"
+)
+
+// 中缀运算 checked为true时检查整数运算是否溢出
+// 默认结果超出int64范围时提升为大整数 检查时 + - * / % ** << 的结果超出int64范围返回OverflowError
+// 求值器从调用栈读取这个设置 虚拟机使用自己的设置 同一个进程中的多次执行互不影响
+func evalCheckedInfix(operator string, left, right object.Object, checked bool) object.Object {
+	result := evalInfixExpression(operator, left, right)
+	if checked && result.Type() == object.BIGINT_OBJ && isInteger(left) && isInteger(right) {
+		switch operator {
+		case "+", "-", "*", "/", "%", "**", "<<":
+			return newError(object.OverflowError, "integer overflow: %s %s %s", left.Inspect(), operator, right.Inspect())
+		}
+	}
+	return result
+}
+
+// 前缀运算 checked为true时取相反数的结果超出int64范围返回OverflowError -(-9223372036854775808)
+func evalCheckedPrefix(operator string, right object.Object, checked bool) object.Object {
+	result := evalPrefixExpression(operator, right)
+	if checked && operator == "-" && result.Type() == object.BIGINT_OBJ {
+		return newError(object.OverflowError, "integer overflow: -(%s)", right.Inspect())
+	}
+	return result
+}
+
+// 整数的四则运算和取余 除数为0时返回错误 溢出时改用大整数计算
+// 除法向0取整 余数的符号和被除数相同 a == a / b * b + a % b
+func evalIntegerArithmetic(operator string, left, right int64) object.Object {
+	var result int64
+	overflow := false
+	switch operator {
+	case "+":
+		result = left + right
+		overflow = (left^result)&(right^result) < 0 // 两个操作数同号 结果的符号和它们不同
+	case "-":
+		result = left - right
+		overflow = (left^right)&(left^result) < 0 // 两个操作数异号 结果的符号和被减数不同
+	case "*":
+		result = left * right
+		overflow = left != 0 && (result/left != right || left == -1 && right == math.MinInt64)
+	case "/":
+		if right == 0 {
+			return newError(object.DivisionByZero, "division by zero")
+		}
+		result = left / right
+		overflow = left == math.MinInt64 && right == -1
+	case "%":
+		if right == 0 {
+			return newError(object.DivisionByZero, "modulo by zero")
+		}
+		result = left % right // 最小的int64 % -1 是0 不会溢出
+	}
+	if overflow {
+		return evalBigIntArithmetic(operator, big.NewInt(left), big.NewInt(right))
+	}
+	return &object.Integer{Value: result}
+}
+
+// 有一边是大整数的中缀表达式
+func evalBigIntInfixExpression(operator string, left, right object.Object) object.Object {
+	leftValue := object.ToBigInt(left)
+	rightValue := object.ToBigInt(right)
+	switch operator {
+	case "+", "-", "*", "/", "%":
+		return evalBigIntArithmetic(operator, leftValue, rightValue)
+	case "**":
+		return evalIntegerPower(leftValue, rightValue)
+	case "<<", ">>":
+		return evalBigIntShift(operator, leftValue, rightValue)
+	case "<":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) < 0)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) > 0)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) <= 0)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) >= 0)
+	case "==":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) == 0)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue.Cmp(rightValue) != 0)
+	case "&": // 按补码计算 和int64的结果一致
+		return object.NewInteger(new(big.Int).And(leftValue, rightValue))
+	case "|":
+		return object.NewInteger(new(big.Int).Or(leftValue, rightValue))
+	case "^":
+		return object.NewInteger(new(big.Int).Xor(leftValue, rightValue))
+	default:
+		return newError(object.TypeError, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// 大整数的四则运算和取余 和int64一样向0取整 结果能用int64表示时转回Integer
+func evalBigIntArithmetic(operator string, left, right *big.Int) object.Object {
+	result := new(big.Int)
+	switch operator {
+	case "+":
+		result.Add(left, right)
+	case "-":
+		result.Sub(left, right)
+	case "*":
+		result.Mul(left, right)
+	case "/":
+		if right.Sign() == 0 {
+			return newError(object.DivisionByZero, "division by zero")
+		}
+		result.Quo(left, right)
+	case "%":
+		if right.Sign() == 0 {
+			return newError(object.DivisionByZero, "modulo by zero")
+		}
+		result.Rem(left, right)
+	}
+	return object.NewInteger(result)
+}
+
+// 整数取相反数 -(-9223372036854775808) 超出int64范围
+func evalBigIntNegation(value *big.Int) object.Object {
+	return object.NewInteger(new(big.Int).Neg(value))
+}
+
+// 整数的幂运算结果最多的位数 避免 2 ** 10000000000 这样的运算耗尽内存
+const maxPowerBits = 1 << 24
+
+// 整数的幂运算 结果是整数 指数是负数时结果是浮点数 2 ** -1 是 0.5
+func evalIntegerPower(base, exponent *big.Int) object.Object {
+	if exponent.Sign() < 0 {
+		if base.Sign() == 0 {
+			return newError(object.DivisionByZero, "zero to a negative power: 0 ** %d", exponent)
+		}
+		return &object.Float{Value: math.Pow(bigToFloat(base), bigToFloat(exponent))}
+	}
+	// 底数是 0 1 -1 时结果不会变大
+	if base.BitLen() > 1 && (!exponent.IsInt64() || exponent.Int64() > maxPowerBits/int64(base.BitLen()-1)) {
+		return newError(object.OverflowError, "exponent too large: %d ** %d", base, exponent)
+	}
+	return object.NewInteger(new(big.Int).Exp(base, exponent, nil))
+}
+
+// 大整数的移位 位数不能是负数 >> 是算术右移 向负无穷取整 -5 >> 1 是 -3
+// 和幂运算一样 << 的结果最多maxPowerBits位
+func evalBigIntShift(operator string, value, count *big.Int) object.Object {
+	if count.Sign() < 0 {
+		return newError(object.ValueError, "shift count out of range: %d", count)
+	}
+	bits := int64(value.BitLen())
+	if operator == ">>" {
+		if !count.IsInt64() || count.Int64() > bits { // 所有的位都被移出 结果是0或者-1
+			count = big.NewInt(bits)
+		}
+		return object.NewInteger(new(big.Int).Rsh(value, uint(count.Int64())))
+	}
+	if value.Sign() == 0 {
+		return &object.Integer{Value: 0}
+	}
+	if !count.IsInt64() || count.Int64() > maxPowerBits-bits {
+		return newError(object.OverflowError, "shift count too large: %d << %d", value, count)
+	}
+	return object.NewInteger(new(big.Int).Lsh(value, uint(count.Int64())))
+}
+
+func bigToFloat(value *big.Int) float64 {
+	return (&object.BigInt{Value: value}).Float64()
+}
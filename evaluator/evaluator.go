@@ -2,15 +2,21 @@ package evaluator
 
 import (
 	"fmt"
+	"math"
+	"math/big"
 	"monkey/ast"
 	"monkey/object"
+	"strings"
+	"unicode/utf8"
 )
 
 // true和false创建引用 只有两个实例
 var (
-	NULL  = &object.Null{}
-	TRUE  = &object.Boolean{Value: true}
-	FALSE = &object.Boolean{Value: false}
+	NULL     = &object.Null{}
+	BREAK    = &object.Break{}
+	CONTINUE = &object.Continue{}
+	TRUE     = &object.Boolean{Value: true}
+	FALSE    = &object.Boolean{Value: false}
 )
 
 func Eval(node ast.Node, env *object.Environment) object.Object {
@@ -25,6 +31,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(val) {
 			return val
 		}
+		if fn, ok := val.(*object.Function); ok && fn.Name == "" {
+			fn.Name = node.Name.Value // 函数名用于调用链 let g = f 时保留原来的名字
+		}
 		// 记录let声明的变量到环境中
 		env.Set(node.Name.Value, val)
 	case *ast.FunctionLiteral:
@@ -40,23 +49,23 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if node.Function.TokenLiteral() == "quote" {
 			return quote(node.Arguments[0], env) // 是quote函数调用 那么不能对参数求值
 		}
-		function := Eval(node.Function, env)
-		if isError(function) {
-			return function
-		}
-		args := evalExpressions(node.Arguments, env)
-		if len(args) == 1 && isError(args[0]) {
-			return args[0]
-		}
-		return applyFunction(function, args)
+		return applyFunction(prepareCall(node, env)) // 函数或者参数求值出错时直接返回错误
 	case *ast.Identifier: // 获取标识符的值 先看环境中是否有记录
-		return evalIdentifier(node, env)
+		return withPos(evalIdentifier(node, env), node)
 	// 表达式
 	// 整数字面量
 	case *ast.IntegerLiteral:
 		return &object.Integer{
 			Value: node.Value,
 		}
+	case *ast.BigIntLiteral:
+		return &object.BigInt{
+			Value: node.Value,
+		}
+	case *ast.FloatLiteral:
+		return &object.Float{
+			Value: node.Value,
+		}
 	case *ast.Boolean:
 		return nativeBoolToBooleanObject(node.Value)
 	case *ast.StringLiteral:
@@ -74,8 +83,11 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return withPos(evalCheckedPrefix(node.Operator, right, env.CallStack().CheckedArithmetic), node)
 	case *ast.InfixExpression:
+		if node.Operator == "&&" || node.Operator == "||" { // 右侧可能不需要求值 不能先求出两侧的值
+			return withPos(evalLogicalExpression(node, env), node)
+		}
 		left := Eval(node.Left, env)
 		if isError(left) {
 			return left
@@ -84,7 +96,9 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(right) {
 			return right
 		}
-		return evalInfixExpression(node.Operator, left, right)
+		return withPos(evalCheckedInfix(node.Operator, left, right, env.CallStack().CheckedArithmetic), node)
+	case *ast.AssignExpression:
+		return withPos(evalAssignExpression(node, env), node)
 	case *ast.IndexExpression: // 索引表达式
 		left := Eval(node.Left, env) // arr[index] arr左操作数 index右操作数
 		if isError(left) {
@@ -94,15 +108,31 @@ func Eval(node ast.Node, env *object.Environment) object.Object {
 		if isError(index) {
 			return index
 		}
-		return evalIndexExpression(left, index)
+		return withPos(evalIndexExpression(left, index), node)
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return withPos(evalHashLiteral(node, env), node)
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return evalBlockStatement(node, env, Eval)
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return evalIfExpression(node, env, Eval)
+	case *ast.WhileStatement:
+		return evalWhileStatement(node, env)
+	case *ast.ForStatement:
+		return evalForStatement(node, env)
+	case *ast.TryExpression:
+		return evalTryExpression(node, env)
+	case *ast.ThrowStatement:
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		return withPos(ThrowValue(val), node)
+	case *ast.BreakStatement:
+		return BREAK
+	case *ast.ContinueStatement:
+		return CONTINUE
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := evalTail(node.ReturnValue, env) // return的值总是在尾部位置
 		if isError(val) {
 			return val
 		}
@@ -118,7 +148,7 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 		result = Eval(statement, env)
 		switch result := result.(type) {
 		case *object.ReturnValue: // 是return语句 不在继续执行后续的求值
-			return result.Value // 返回结果
+			return applyFunction(result.Value) // 返回结果 顶层的return也可能是尾调用
 		case *object.Error: // 是错误 也返回 阻止求值
 			return result
 		}
@@ -126,16 +156,23 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-// 解析块级语句 if () {xx}
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+// 解析块级语句 if () {xx} 最后一条语句用evalLast求值 在尾部位置时是evalTail
+func evalBlockStatement(block *ast.BlockStatement, env *object.Environment, evalLast evalFunc) object.Object {
 	var result object.Object
-	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+	for i, statement := range block.Statements {
+		if i == len(block.Statements)-1 {
+			result = evalLast(statement, env)
+		} else {
+			result = Eval(statement, env)
+		}
 		if result != nil {
 			rt := result.Type()
 			if rt == object.RETURN_VALUE_OBJ || rt == object.ERROR_OBJ { // 可能是错误对象
 				return result // 不解包return 会在evalProgram想解包拿到return的value 嵌套块的解决
 			}
+			if rt == object.BREAK_OBJ || rt == object.CONTINUE_OBJ {
+				return result // 交给所在的循环处理
+			}
 		}
 	}
 	return result
@@ -156,8 +193,10 @@ func evalPrefixExpression(operator string, right object.Object) object.Object {
 		return evalBangOperatorExpression(right)
 	case "-":
 		return evalMinusPrefixOperatorExpression(right)
+	case "~":
+		return evalBitNotPrefixOperatorExpression(right)
 	default: // 不支持该运算符
-		return newError("unknown operator: %s%s", operator, right.Type())
+		return newError(object.TypeError, "unknown operator: %s%s", operator, right.Type())
 	}
 }
 
@@ -177,30 +216,71 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 
 // 返回 表达式的值的相反数
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.INTEGER_OBJ { // 不是整数
-		return newError("unknown operator: -%s", right.Type())
+	switch right := right.(type) {
+	case *object.Integer:
+		if right.Value == math.MinInt64 { // 相反数超出int64范围
+			return evalBigIntNegation(big.NewInt(right.Value))
+		}
+		return &object.Integer{Value: -right.Value}
+	case *object.BigInt:
+		return evalBigIntNegation(right.Value)
+	case *object.Float:
+		return &object.Float{Value: -right.Value}
+	default: // 不是数字
+		return newError(object.TypeError, "unknown operator: -%s", right.Type())
+	}
+}
+
+// 按位取反 只支持整数 ~x 等于 -x-1
+func evalBitNotPrefixOperatorExpression(right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.Integer:
+		return &object.Integer{Value: ^right.Value}
+	case *object.BigInt:
+		return object.NewInteger(new(big.Int).Not(right.Value))
+	default:
+		return newError(object.TypeError, "unknown operator: ~%s", right.Type())
+	}
+}
+
+// 短路求值 && 左侧为假时 || 左侧为真时 结果就是左侧的值 不再对右侧求值
+// 否则结果是右侧的值 结果不一定是布尔值 1 && "a" 的值是 "a"
+func evalLogicalExpression(node *ast.InfixExpression, env *object.Environment) object.Object {
+	left := Eval(node.Left, env)
+	if isError(left) {
+		return left
 	}
-	value := right.(*object.Integer).Value
-	return &object.Integer{
-		Value: -value,
+	if isTruthy(left) == (node.Operator == "||") {
+		return left
 	}
+	return Eval(node.Right, env)
 }
 
 // 中缀表达式
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
+	case operator == "in":
+		return evalInExpression(left, right)
 	case left.Type() == object.INTEGER_OBJ && right.Type() == object.INTEGER_OBJ:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isInteger(left) && isInteger(right): // 有一边是大整数
+		return evalBigIntInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right): // 有一边是浮点数 整数提升为浮点数
+		return evalFloatInfixExpression(operator, left, right)
 	case left.Type() == object.STRING_OBJ && right.Type() == object.STRING_OBJ:
 		return evalStringInfixExpression(operator, left, right)
+	case operator == "*" && left.Type() == object.STRING_OBJ && isInteger(right):
+		return evalStringRepetition(left, right)
+	case operator == "*" && isInteger(left) && right.Type() == object.STRING_OBJ:
+		return evalStringRepetition(right, left)
 	case operator == "==": // 对于布尔类型的 == != 比较 因为底层的true和false是相同的object表示 这样是很合适比较的
 		return nativeBoolToBooleanObject(left == right)
 	case operator == "!=":
 		return nativeBoolToBooleanObject(left != right)
 	case left.Type() != right.Type():
-		return newError("type mismatch: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeError, "type mismatch: %s %s %s", left.Type(), operator, right.Type())
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeError, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
@@ -209,51 +289,185 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	leftValue := left.(*object.Integer).Value
 	rightValue := right.(*object.Integer).Value
 	switch operator {
+	case "+", "-", "*", "/", "%":
+		return evalIntegerArithmetic(operator, leftValue, rightValue)
+	case "**":
+		return evalIntegerPower(big.NewInt(leftValue), big.NewInt(rightValue))
+	case "<":
+		return nativeBoolToBooleanObject(leftValue < rightValue)
+	case ">":
+		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
+	case "==":
+		return nativeBoolToBooleanObject(leftValue == rightValue)
+	case "!=":
+		return nativeBoolToBooleanObject(leftValue != rightValue)
+	case "&":
+		return &object.Integer{Value: leftValue & rightValue}
+	case "|":
+		return &object.Integer{Value: leftValue | rightValue}
+	case "^":
+		return &object.Integer{Value: leftValue ^ rightValue}
+	case "<<", ">>":
+		return evalShiftExpression(operator, leftValue, rightValue)
+	default:
+		return newError(object.TypeError, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// 移位 结果能用int64表示时直接计算 否则和其他运算一样改用大整数计算
+func evalShiftExpression(operator string, value, count int64) object.Object {
+	if count >= 0 && count < 64 {
+		if operator == ">>" {
+			return &object.Integer{Value: value >> count}
+		}
+		if result := value << count; result>>count == value { // 移出的位和符号位相同 没有溢出
+			return &object.Integer{Value: result}
+		}
+	}
+	return evalBigIntShift(operator, big.NewInt(value), big.NewInt(count))
+}
+
+// 是否是整数 Integer或者BigInt
+func isInteger(obj object.Object) bool {
+	return obj.Type() == object.INTEGER_OBJ || obj.Type() == object.BIGINT_OBJ
+}
+
+// 是否是数字 整数或者浮点数
+func isNumber(obj object.Object) bool {
+	return isInteger(obj) || obj.Type() == object.FLOAT_OBJ
+}
+
+// 数字转换为浮点数 调用前需要确认是数字
+func toFloat(obj object.Object) float64 {
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return float64(obj.Value)
+	case *object.BigInt:
+		return obj.Float64()
+	}
+	return obj.(*object.Float).Value
+}
+
+// 浮点数的中缀表达式 除以0得到Inf或者NaN 对0取余得到NaN
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftValue := toFloat(left)
+	rightValue := toFloat(right)
+	switch operator {
 	case "+":
-		return &object.Integer{Value: leftValue + rightValue}
+		return &object.Float{Value: leftValue + rightValue}
 	case "-":
-		return &object.Integer{Value: leftValue - rightValue}
+		return &object.Float{Value: leftValue - rightValue}
 	case "*":
-		return &object.Integer{Value: leftValue * rightValue}
+		return &object.Float{Value: leftValue * rightValue}
 	case "/":
-		return &object.Integer{Value: leftValue / rightValue}
+		return &object.Float{Value: leftValue / rightValue}
+	case "%": // 余数的符号和被除数相同
+		return &object.Float{Value: math.Mod(leftValue, rightValue)}
+	case "**":
+		return &object.Float{Value: math.Pow(leftValue, rightValue)}
 	case "<":
 		return nativeBoolToBooleanObject(leftValue < rightValue)
 	case ">":
 		return nativeBoolToBooleanObject(leftValue > rightValue)
+	case "<=":
+		return nativeBoolToBooleanObject(leftValue <= rightValue)
+	case ">=":
+		return nativeBoolToBooleanObject(leftValue >= rightValue)
 	case "==":
 		return nativeBoolToBooleanObject(leftValue == rightValue)
 	case "!=":
 		return nativeBoolToBooleanObject(leftValue != rightValue)
 	default:
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+		return newError(object.TypeError, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
 }
 
-// TODO 支持字符串比较 ？ == !=
-// 字符串中缀表达式
+// 字符串中缀表达式 比较的是字符串的内容 不是对象本身
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != "+" { // 只支持字符串拼接
-		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
-	}
 	leftVal := left.(*object.String).Value
 	rightVal := right.(*object.String).Value
-	return &object.String{
-		Value: leftVal + rightVal,
+	switch operator {
+	case "+": // 字符串拼接
+		return &object.String{
+			Value: leftVal + rightVal,
+		}
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	case "<": // 按字节的字典序比较
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	default:
+		return newError(object.TypeError, "unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+// 重复之后的字符串最多的字节数 避免 "a" * 10000000000 这样的运算耗尽内存
+const maxRepeatLength = 1 << 30
+
+// 字符串重复 "ab" * 3 和 3 * "ab" 都是 "ababab" 次数不是正数时是空字符串
+func evalStringRepetition(str, count object.Object) object.Object {
+	value := str.(*object.String).Value
+	times := object.ToBigInt(count)
+	if value == "" || times.Sign() <= 0 {
+		return &object.String{Value: ""}
+	}
+	if !times.IsInt64() || times.Int64() > maxRepeatLength/int64(len(value)) {
+		return newError(object.OverflowError, "repeated string too long: %d bytes * %d", len(value), times)
 	}
+	return &object.String{Value: strings.Repeat(value, int(times.Int64()))}
 }
 
-// if-else语句
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+// in 运算 右侧是字符串时判断左侧是不是它的子串 是数组时判断有没有和左侧相等(==)的元素
+// 是hash时判断有没有这个键 整数值的浮点数和对应的整数是同一个键
+func evalInExpression(left, right object.Object) object.Object {
+	switch right := right.(type) {
+	case *object.String:
+		sub, ok := left.(*object.String)
+		if !ok {
+			return newError(object.TypeError, "type mismatch: %s in %s", left.Type(), right.Type())
+		}
+		return nativeBoolToBooleanObject(strings.Contains(right.Value, sub.Value))
+	case *object.Array:
+		for _, el := range right.Elements {
+			if evalInfixExpression("==", left, el) == TRUE {
+				return TRUE
+			}
+		}
+		return FALSE
+	case *object.Hash:
+		key, ok := left.(object.Hashable)
+		if !ok {
+			return newError(object.TypeError, "unusable as hash key: %s", left.Type())
+		}
+		_, ok = right.Pairs[key.HashKey()]
+		return nativeBoolToBooleanObject(ok)
+	default:
+		return newError(object.TypeError, "unknown operator: %s in %s", left.Type(), right.Type())
+	}
+}
+
+// if-else语句 分支用evalBranch求值 if在尾部位置时分支也在尾部位置
+func evalIfExpression(ie *ast.IfExpression, env *object.Environment, evalBranch evalFunc) object.Object {
 	condition := Eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return evalBranch(ie.Consequence, env)
 	}
 	if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return evalBranch(ie.Alternative, env)
 	}
 	// 没有else语句
 	return NULL
@@ -274,10 +488,19 @@ func isTruthy(obj object.Object) bool {
 }
 
 // 创建错误对象
-func newError(format string, a ...any) *object.Error {
+func newError(kind, format string, a ...any) *object.Error {
 	return &object.Error{
 		Message: fmt.Sprintf(format, a...),
+		Kind:    kind,
+	}
+}
+
+// 记录错误发生的位置 已经有位置的错误保持不变 保留最内层的位置
+func withPos(obj object.Object, node ast.Node) object.Object {
+	if err, ok := obj.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = node.Pos()
 	}
+	return obj
 }
 
 // 判断是否是错误
@@ -296,7 +519,7 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 	if builtin, ok := builtins[node.Value]; ok {
 		return builtin // 找内置的函数
 	}
-	return newError("identifier not found: " + node.Value)
+	return newError(object.NameError, "identifier not found: "+node.Value)
 }
 
 // 解析表达式
@@ -312,17 +535,103 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-// 执行函数
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+type evalFunc func(node ast.Node, env *object.Environment) object.Object
+
+// 尾调用 函数和实参都已经求值 但是还没有执行 交给applyFunction的循环执行
+// 尾部位置的调用因此不会增加Go的调用栈 递归的Monkey函数只占用固定的栈空间
+type tailCall struct {
+	fn   object.Object
+	args []object.Object
+	node *ast.CallExpression // 出错时记录调用的位置
+}
+
+func (tc *tailCall) Type() object.ObjectType {
+	return "TAIL_CALL"
+}
+
+func (tc *tailCall) Inspect() string {
+	return "tail call"
+}
+
+// 对函数和实参求值 返回还没有执行的调用 出错时返回错误
+func prepareCall(node *ast.CallExpression, env *object.Environment) object.Object {
+	function := Eval(node.Function, env)
+	if isError(function) {
+		return function
+	}
+	args := evalExpressions(node.Arguments, env)
+	if len(args) == 1 && isError(args[0]) {
+		return args[0]
+	}
+	return &tailCall{fn: function, args: args, node: node}
+}
+
+// 对尾部位置的节点求值 函数体的最后一条语句 尾部位置的if的分支 return的值
+// 尾部位置的函数调用不立即执行 而是返回tailCall
+func evalTail(node ast.Node, env *object.Environment) object.Object {
+	switch node := node.(type) {
+	case *ast.ExpressionStatement:
+		return evalTail(node.Expression, env)
+	case *ast.BlockStatement:
+		return evalBlockStatement(node, env, evalTail)
+	case *ast.IfExpression:
+		return evalIfExpression(node, env, evalTail)
+	case *ast.CallExpression:
+		if node.Function.TokenLiteral() != "quote" {
+			return prepareCall(node, env)
+		}
+	}
+	return Eval(node, env)
+}
+
+// 执行prepareCall准备好的函数调用 尾调用在这里循环执行 直到得到真正的结果
+// 调用用户函数时在调用栈中压入一帧 之后的尾调用复用这一帧 所以不增加调用深度
+// 错误向外传递经过这里时 把这一帧添加到错误的调用链中
+func applyFunction(result object.Object) object.Object {
+	var calls *object.CallStack
+	for {
+		call, ok := result.(*tailCall)
+		if !ok {
+			return result
+		}
+		if fn, ok := call.fn.(*object.Function); ok {
+			frame := object.Frame{Function: fn.Name, Pos: call.node.Pos(), NumArgs: len(call.args)}
+			if calls == nil {
+				calls = fn.Env.CallStack()
+				if len(calls.Frames) >= calls.MaxDepth { // 求值器不会因为Go的栈溢出而退出
+					return withPos(newError(object.RecursionError, "maximum call depth %d exceeded", calls.MaxDepth), call.node)
+				}
+				calls.Frames = append(calls.Frames, frame)
+				defer func() { calls.Frames = calls.Frames[:len(calls.Frames)-1] }()
+			} else { // 调用的位置是调用方中最初的调用 所以保留最初调用的函数 标记之后执行了尾调用
+				top := &calls.Frames[len(calls.Frames)-1]
+				top.Tail, top.TailCall = true, fn.Name
+			}
+		}
+		result = withPos(callFunction(call.fn, call.args), call.node)
+		if err, ok := result.(*object.Error); ok && calls != nil {
+			err.Trace = append(err.Trace, calls.Frames[len(calls.Frames)-1])
+		}
+	}
+}
+
+// 执行一次函数调用 函数体最后的调用作为tailCall返回
+func callFunction(fn object.Object, args []object.Object) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError(object.ArityError, "wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+		}
 		extendsEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendsEnv)
+		evaluated := evalTail(fn.Body, extendsEnv)
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin: // 内置函数
-		return fn.Fn(args...)
+		if result := fn.Fn(args...); result != nil {
+			return result
+		}
+		return NULL // 内置函数返回nil表示null
 	default:
-		return newError("not a function: %s", fn.Type())
+		return newError(object.TypeError, "not a function: %s", fn.Type())
 	}
 }
 
@@ -347,23 +656,199 @@ func unwrapReturnValue(obj object.Object) object.Object {
 func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	// 左侧是数组类型 右侧是数字类型
-	case left.Type() == object.ARRAY_OBJ && index.Type() == object.INTEGER_OBJ:
+	case left.Type() == object.ARRAY_OBJ && isInteger(index):
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.STRING_OBJ && isInteger(index):
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.HASH_OBJ:
 		return evalHashIndexExpression(left, index) // hash索引表达式求值
 	default:
-		return newError("index operator not supported: %s", left.Type())
+		return newError(object.TypeError, "index operator not supported: %s", left.Type())
+	}
+}
+
+// 字符串按字符(Unicode码点)索引 不是按字节 "中文"[1] 是 "文" 和len的计数方式一致
+// 结果是只包含一个字符的字符串 越界时和数组一样返回null
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	value := str.(*object.String).Value
+	integer, ok := index.(*object.Integer)
+	if !ok || integer.Value < 0 { // 大整数一定越界
+		return NULL
 	}
+	idx := integer.Value
+	for i := range value {
+		if idx == 0 { // 不合法的utf-8字节单独作为一个字符
+			_, size := utf8.DecodeRuneInString(value[i:])
+			return &object.String{Value: value[i : i+size]}
+		}
+		idx--
+	}
+	return NULL // 越界
 }
 
 func evalArrayIndexExpression(array, index object.Object) object.Object {
 	arrayObject := array.(*object.Array)        // 数组
-	idx := index.(*object.Integer).Value        // 下标
+	integer, ok := index.(*object.Integer)      // 下标 大整数一定越界
 	max := int64(len(arrayObject.Elements) - 1) // 最大下标
-	if idx < 0 || idx > max {
+	if !ok || integer.Value < 0 || integer.Value > max {
 		return NULL // 越界
 	}
-	return arrayObject.Elements[idx]
+	return arrayObject.Elements[integer.Value]
+}
+
+// 赋值表达式 复合赋值先取出当前的值 再和右侧的值运算 x += 1 就是 x = x + 1
+// 标识符沿着外层环境找到已有的绑定修改 数组和hash的元素原地修改
+func evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	operator := strings.TrimSuffix(node.Operator, "=") // 复合赋值对应的中缀运算符 = 是空字符串
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if operator != "" {
+			current = evalIdentifier(target, env)
+			if isError(current) {
+				return current
+			}
+		}
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if operator != "" {
+			val = evalCheckedInfix(operator, current, val, env.CallStack().CheckedArithmetic)
+			if isError(val) {
+				return val
+			}
+		}
+		if _, ok := env.Assign(target.Value, val); !ok {
+			if _, ok := builtins[target.Value]; ok {
+				return newError(object.NameError, "cannot assign to builtin %s", target.Value)
+			}
+			return newError(object.NameError, "identifier not found: "+target.Value)
+		}
+		return val
+	case *ast.IndexExpression:
+		left := Eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+		index := Eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+		var current object.Object
+		if operator != "" {
+			current = evalIndexExpression(left, index)
+			if isError(current) {
+				return current
+			}
+		}
+		val := Eval(node.Value, env)
+		if isError(val) {
+			return val
+		}
+		if operator != "" {
+			val = evalCheckedInfix(operator, current, val, env.CallStack().CheckedArithmetic)
+			if isError(val) {
+				return val
+			}
+		}
+		return evalSetIndexExpression(left, index, val)
+	default:
+		return newError(object.TypeError, "invalid assignment target %s", node.Target.String())
+	}
+}
+
+// 给数组或者hash的元素赋值 修改的是原来的对象 所有引用它的地方都能看到
+// 数组越界是错误 不会自动扩展 hash没有这个键时添加
+func evalSetIndexExpression(left, index, val object.Object) object.Object {
+	switch {
+	case left.Type() == object.ARRAY_OBJ && isInteger(index):
+		elements := left.(*object.Array).Elements
+		idx, ok := index.(*object.Integer)
+		if !ok || idx.Value < 0 || idx.Value >= int64(len(elements)) {
+			return newError(object.IndexError, "index out of range: %s, array length is %d", index.Inspect(), len(elements))
+		}
+		elements[idx.Value] = val
+		return val
+	case left.Type() == object.HASH_OBJ:
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError(object.TypeError, "unusable as hash key: %s", index.Type())
+		}
+		left.(*object.Hash).Pairs[key.HashKey()] = object.HashPair{Key: index, Value: val}
+		return val
+	default:
+		return newError(object.TypeError, "index assignment not supported: %s[%s]", left.Type(), index.Type())
+	}
+}
+
+// while循环 循环是语句 没有值
+func evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := Eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+		if !isTruthy(condition) {
+			return nil
+		}
+		if result, stop := evalLoopBody(node.Body, env); stop {
+			return result
+		}
+	}
+}
+
+// for-in循环 循环变量和let一样绑定在当前环境中 循环结束后仍然可以访问
+func evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := Eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+	items, err := iterableItems(iterable)
+	if err != nil {
+		return withPos(err, node)
+	}
+	for _, item := range items {
+		env.Set(node.Variable.Value, item)
+		if result, stop := evalLoopBody(node.Body, env); stop {
+			return result
+		}
+	}
+	return nil
+}
+
+// 执行一次循环体 stop表示循环需要结束 result是循环的结果 break时为nil return和错误继续向外传递
+func evalLoopBody(body *ast.BlockStatement, env *object.Environment) (result object.Object, stop bool) {
+	result = Eval(body, env)
+	if result == nil {
+		return nil, false
+	}
+	switch result.Type() {
+	case object.BREAK_OBJ:
+		return nil, true
+	case object.RETURN_VALUE_OBJ, object.ERROR_OBJ:
+		return result, true
+	}
+	return nil, false
+}
+
+// for-in循环依次访问的值 数组是元素 hash是排好序的键 字符串是每个字符
+func iterableItems(obj object.Object) ([]object.Object, *object.Error) {
+	switch obj := obj.(type) {
+	case *object.Array:
+		return obj.Elements, nil
+	case *object.Hash:
+		return obj.SortedKeys(), nil
+	case *object.String:
+		chars := []object.Object{}
+		for i, size := 0, 0; i < len(obj.Value); i += size {
+			_, size = utf8.DecodeRuneInString(obj.Value[i:]) // 和下标一样 不合法的字节单独作为一个字符
+			chars = append(chars, &object.String{Value: obj.Value[i : i+size]})
+		}
+		return chars, nil
+	default:
+		return nil, newError(object.TypeError, "cannot iterate over %s", obj.Type())
+	}
 }
 
 // 处理hash数据结构
@@ -376,7 +861,7 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 		}
 		hashKey, ok := key.(object.Hashable)
 		if !ok {
-			return newError("unusable as hash key: %s", key.Type())
+			return newError(object.TypeError, "unusable as hash key: %s", key.Type())
 		}
 		value := Eval(valueNode, env)
 		if isError(value) {
@@ -398,7 +883,7 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 	key, ok := index.(object.Hashable)
 	if !ok {
-		return newError("unusable as hash key: %s", index.Type())
+		return newError(object.TypeError, "unusable as hash key: %s", index.Type())
 	}
 	pair, ok := hashObject.Pairs[key.HashKey()]
 	if !ok {
@@ -406,3 +891,40 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	}
 	return pair.Value
 }
+
+// ============= 供字节码虚拟机复用 保证两种后端的运算语义一致 ================
+
+// EvalPrefix 前缀运算 checked为true时检查整数运算是否溢出
+func EvalPrefix(operator string, right object.Object, checked bool) object.Object {
+	return evalCheckedPrefix(operator, right, checked)
+}
+
+// EvalInfix 中缀运算 checked为true时检查整数运算是否溢出
+func EvalInfix(operator string, left, right object.Object, checked bool) object.Object {
+	return evalCheckedInfix(operator, left, right, checked)
+}
+
+// EvalIndex 索引运算
+func EvalIndex(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// EvalSetIndex 给数组或者hash的元素赋值
+func EvalSetIndex(left, index, val object.Object) object.Object {
+	return evalSetIndexExpression(left, index, val)
+}
+
+// IterableItems for-in循环依次访问的值
+func IterableItems(obj object.Object) ([]object.Object, *object.Error) {
+	return iterableItems(obj)
+}
+
+// IsTruthy 真值判断
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// NativeBoolToBooleanObject 返回缓存的布尔值对象
+func NativeBoolToBooleanObject(input bool) *object.Boolean {
+	return nativeBoolToBooleanObject(input)
+}
@@ -1,9 +1,11 @@
 package evaluator
 
 import (
+	"fmt"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"runtime/debug"
 	"testing"
 )
 
@@ -202,6 +204,56 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+// 字符串的长度和下标都按字符(Unicode码点)计算 不是字节
+func TestStringLengthAndIndexing(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`len("hello")`, 5},
+		{`len("中文")`, 2},
+		{`len("😀!")`, 2},
+		{`len("e\u{301}")`, 2}, // 组合字符是单独的码点
+		{`"hello"[1]`, "e"},
+		{`"中文"[0]`, "中"},
+		{`"中文"[1]`, "文"},
+		{`"a😀b"[1]`, "😀"},
+		{`"a😀b"[2]`, "b"},
+		{`"中文"[2]`, nil},
+		{`"中文"[-1]`, nil},
+		{`""[0]`, nil},
+		{`let s = "猴子"; s[len(s) - 1]`, "子"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok {
+				t.Errorf("%s: object is not String. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if str.Value != expected {
+				t.Errorf("%s: expected %q, got %q", tt.input, expected, str.Value)
+			}
+		case nil:
+			testNullObject(t, evaluated)
+		}
+	}
+}
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := `
+	let 加 = fn(甲, 乙) { 甲 + 乙 };
+	let 结果 = 加(1, 2);
+	结果
+	`
+	testIntegerObject(t, testEval(input), 3)
+}
+
 func TestStringConcatenation(t *testing.T) {
 	input := `"Hello" + " " + "World!"`
 
@@ -374,6 +426,101 @@ if (10 > 1) {
 	}
 }
 
+// 每一种错误在各种位置都会停止求值 而不是被当作普通的值继续参与计算
+func TestErrorKinds(t *testing.T) {
+	errors := []struct {
+		input   string
+		kind    string
+		message string
+	}{
+		{"5 + true", object.TypeError, "type mismatch: INTEGER + BOOLEAN"},
+		{"-true", object.TypeError, "unknown operator: -BOOLEAN"},
+		{`~"a"`, object.TypeError, "unknown operator: ~STRING"},
+		{`"a" - "b"`, object.TypeError, "unknown operator: STRING - STRING"},
+		{"1 << -1", object.ValueError, "shift count out of range: -1"},
+		{"18446744073709551616 >> -18446744073709551616", object.ValueError, "shift count out of range: -18446744073709551616"},
+		{"1 << 100000000", object.OverflowError, "shift count too large: 1 << 100000000"},
+		{"1 / 0", object.DivisionByZero, "division by zero"},
+		{"1 % 0", object.DivisionByZero, "modulo by zero"},
+		{"0 ** -2", object.DivisionByZero, "zero to a negative power: 0 ** -2"},
+		{"foobar", object.NameError, "identifier not found: foobar"},
+		{"foobar = 1", object.NameError, "identifier not found: foobar"},
+		{"len = 1", object.NameError, "cannot assign to builtin len"},
+		{"1(2)", object.TypeError, "not a function: INTEGER"},
+		{"fn(x) { x }()", object.ArityError, "wrong number of arguments: want=1, got=0"},
+		{"fn() { 1 }(1)", object.ArityError, "wrong number of arguments: want=0, got=1"},
+		{"len(1, 2)", object.ArityError, "wrong number of arguments. got=2, want=1"},
+		{"len(1)", object.TypeError, "argument to `len` not supported, got INTEGER"},
+		{"first(1)", object.TypeError, "argument to `first` must be ARRAY, got INTEGER"},
+		{`int("x")`, object.ValueError, `could not parse "x" as integer`},
+		{`float("1.2.3")`, object.ValueError, `could not parse "1.2.3" as float`},
+		{"fn(n) { let r = fn(n) { r(n) + 1 }; r(n) }(1)", object.RecursionError, "maximum call depth 10000 exceeded"},
+		{"1[0]", object.TypeError, "index operator not supported: INTEGER"},
+		{"[1][5] = 2", object.IndexError, "index out of range: 5, array length is 1"},
+		{"1[0] = 2", object.TypeError, "index assignment not supported: INTEGER[INTEGER]"},
+		{"{}[fn() { 1 }]", object.TypeError, "unusable as hash key: FUNCTION"},
+		{"{[1]: 2}", object.TypeError, "unusable as hash key: ARRAY"},
+		{`1 in "a"`, object.TypeError, "type mismatch: INTEGER in STRING"},
+		{`"a" * 18446744073709551616`, object.OverflowError, "repeated string too long: 1 bytes * 18446744073709551616"},
+		{"fn() { for (x in 1) { x } }()", object.TypeError, "cannot iterate over INTEGER"},
+	}
+	// 错误出现的位置 之后的代码都不应该执行
+	contexts := []string{
+		"%s",
+		"%s; 99",
+		"if (true) { %s; 99 }",
+		"let f = fn() { %s; 99 }; f(); 99",
+		"let f = fn() { return %s; }; let r = f(); 99",
+		"1 + (%s)",
+		"!(%s)",
+		"[1, %s, 3]",
+		"{1: %s}",
+		"len([%s])",
+		"(%s)[0]",
+		"let i = 0; while (i < 3) { i += 1; %s; }; i",
+		"for (x in [1, 2]) { %s; }; 99",
+		"let x = %s; 99",
+	}
+
+	for _, e := range errors {
+		for _, c := range contexts {
+			input := fmt.Sprintf(c, e.input)
+			evaluated := testEval(input)
+
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: no error object returned. got=%T(%+v)", input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Kind != e.kind {
+				t.Errorf("%s: wrong error kind. expected=%q, got=%q", input, e.kind, errObj.Kind)
+			}
+			if errObj.Message != e.message {
+				t.Errorf("%s: wrong error message. expected=%q, got=%q", input, e.message, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"5 + true;", "ERROR: 1:3: type mismatch: INTEGER + BOOLEAN"},
+		{"let a = 1;\n  -true", "ERROR: 2:3: unknown operator: -BOOLEAN"},
+		{"let f = fn() {\n  foobar\n};\nf()", "ERROR: 2:3: identifier not found: foobar"},
+		{`len(1)`, "ERROR: 1:4: argument to `len` not supported, got INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
 func TestReturnStatements(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -402,6 +549,182 @@ if (10 > 1) {
 		testIntegerObject(t, evaluated, tt.expected)
 	}
 }
+
+// 尾部位置的调用不增加Go的调用栈 限制栈的大小 没有尾调用优化时进程会因为栈溢出退出
+func TestTailCalls(t *testing.T) {
+	defer debug.SetMaxStack(debug.SetMaxStack(16 << 20))
+
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{"let countdown = fn(n) { if (n == 0) { 0 } else { countdown(n - 1) } }; countdown(100000)", 0},
+		{"let sum = fn(n, acc) { if (n == 0) { return acc; } return sum(n - 1, acc + n); }; sum(100000, 0)", 5000050000},
+		{"let loop = fn(n) { if (n > 0) { let m = n - 1; if (true) { loop(m) } } else { \"done\" } }; loop(100000)", "done"},
+		{"let loop = fn(n) { while (true) { if (n == 0) { return n; } return loop(n - 1); } }; loop(100000)", 0},
+		{
+			`let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+			let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+			isEven(100001)`,
+			false,
+		},
+		{"let id = fn(x) { x }; let f = fn(n) { id(n) + 1 }; f(1)", 2},                  // 不在尾部位置的调用
+		{"let f = fn(g) { g(2) }; f(fn(x) { x * 3 })", 6},                               // 调用参数中的函数
+		{"let f = fn(n) { if (n == 0) { return len(\"abc\"); } f(n - 1) }; f(10)", 3},   // 内置函数的尾调用
+		{"let f = fn(n) { if (n == 0) { 7 } else { f(n - 1) } }; return f(100000);", 7}, // 顶层的return
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			str, ok := evaluated.(*object.String)
+			if !ok || str.Value != expected {
+				t.Errorf("%s: object is not String %q. got=%T (%+v)", tt.input, expected, evaluated, evaluated)
+			}
+		}
+	}
+}
+
+func TestTailCallErrorPosition(t *testing.T) {
+	input := "let f = fn(n) {\n  if (n == 0) { len(n) } else { f(n - 1) }\n};\nf(3)"
+	expected := "ERROR: 2:20: argument to `len` not supported, got INTEGER"
+	if evaluated := testEval(input); evaluated.Inspect() != expected {
+		t.Errorf("wrong error. want=%q, got=%q", expected, evaluated.Inspect())
+	}
+}
+
+func TestCallDepthLimit(t *testing.T) {
+	input := "let f = fn(n) { f(n + 1); 0 };\nf(0)"
+	env := object.NewEnvironment()
+	evaluated := Eval(parser.New(lexer.New(input)).ParseProgram(), env)
+
+	err, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+	if err.Kind != object.RecursionError {
+		t.Errorf("wrong error kind. got=%q", err.Kind)
+	}
+	if err.Message != "maximum call depth 10000 exceeded" {
+		t.Errorf("wrong error message. got=%q", err.Message)
+	}
+	if err.Pos.String() != "1:18" {
+		t.Errorf("wrong error position. got=%s", err.Pos)
+	}
+	if len(err.Trace) != object.DefaultMaxCallDepth {
+		t.Fatalf("wrong trace length. got=%d", len(err.Trace))
+	}
+	if got := err.Trace[0].String(); got != "f(1 arg) called at 1:18" {
+		t.Errorf("wrong innermost frame. got=%q", got)
+	}
+	if got := err.Trace[len(err.Trace)-1].String(); got != "f(1 arg) called at 2:2" {
+		t.Errorf("wrong outermost frame. got=%q", got)
+	}
+	if len(env.CallStack().Frames) != 0 {
+		t.Errorf("call stack not unwound. got=%d frames", len(env.CallStack().Frames))
+	}
+}
+
+func TestCallDepthLimitConfigurable(t *testing.T) {
+	tests := []struct {
+		input    string
+		maxDepth int
+		expected string
+	}{
+		{"let f = fn(n) { f(n + 1); 0 }; f(0)", 50, "ERROR: 1:18: maximum call depth 50 exceeded"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { let r = f(n - 1); r } }; f(50)", 51, "0"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { let r = f(n - 1); r } }; f(50)", 50, "ERROR: 1:51: maximum call depth 50 exceeded"},
+		{"let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(1000)", 10, "0"}, // 尾调用不增加调用深度
+		{"let g = fn() { len(\"ab\") }; let f = fn(n) { g(); g() }; f(1)", 1, "ERROR: 1:46: maximum call depth 1 exceeded"},
+	}
+
+	for _, tt := range tests {
+		env := object.NewEnvironment()
+		env.CallStack().MaxDepth = tt.maxDepth
+		evaluated := Eval(parser.New(lexer.New(tt.input)).ParseProgram(), env)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: want=%q, got=%q", tt.input, tt.expected, evaluated.Inspect())
+		}
+	}
+}
+
+func TestErrorTrace(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			"let g = fn(x) {\n  y\n};\nlet f = fn(a, b) { let r = g(a); r };\nf(1, 2)",
+			`Traceback (most recent call last):
+  line 5, column 2, in <program>
+  line 4, column 29, in f(2 args)
+  line 2, column 3, in g(1 arg)
+`,
+		},
+		{ // 尾调用复用了f的调用帧 调用的位置是f的 标记最后尾调用的函数
+			"let g = fn(x) {\n  len(x)\n};\nlet f = fn(a) { g(a) };\nf(1)",
+			`Traceback (most recent call last):
+  line 5, column 2, in <program>
+  line 2, column 6, in f(1 arg) (tail call to g)
+`,
+		},
+		{
+			"let g = fn() { 1 / 0 };\nlet h = fn() { g() };\nlet f = fn() { let x = h(); x };\nf()",
+			`Traceback (most recent call last):
+  line 4, column 2, in <program>
+  line 3, column 25, in f(0 args)
+  line 1, column 18, in h(0 args) (tail call to g)
+`,
+		},
+		{
+			"fn() { let r = fn(x) { x(1) }(2); r }()",
+			`Traceback (most recent call last):
+  line 1, column 38, in <program>
+  line 1, column 30, in <anonymous>(0 args)
+  line 1, column 25, in <anonymous>(1 arg)
+`,
+		},
+		{"len(1)", ""}, // 不在函数中的错误没有调用链
+	}
+
+	for _, tt := range tests {
+		err, ok := testEval(tt.input).(*object.Error)
+		if !ok {
+			t.Fatalf("%s: no error object returned", tt.input)
+		}
+		if got := err.Traceback(); got != tt.expected {
+			t.Errorf("%s: wrong traceback. want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestFunctionNames(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let add = fn(a, b) { a + b }; add", "add"},
+		{"let add = fn(a, b) { a + b }; let plus = add; plus", "add"},
+		{"fn(x) { x }", ""},
+		{"let make = fn() { fn() { 1 } }; let one = make(); one", "one"},
+	}
+
+	for _, tt := range tests {
+		fn, ok := testEval(tt.input).(*object.Function)
+		if !ok {
+			t.Fatalf("%s: object is not Function", tt.input)
+		}
+		if fn.Name != tt.expected {
+			t.Errorf("%s: wrong name. want=%q, got=%q", tt.input, tt.expected, fn.Name)
+		}
+	}
+}
+
 func TestIfElseExpressions(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -506,6 +829,729 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestIntegerDivisionByZero(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 / 0", "division by zero"},
+		{"0 / 0", "division by zero"},
+		{"let x = 10; x /= 0; x", "division by zero"},
+		{"let f = fn(a, b) { a / b }; let r = f(1, 0); r", "division by zero"},
+		{"1.0 / 0 > 1e308", true}, // 浮点数除以0得到无穷大
+	}
+
+	for _, tt := range tests {
+		testArithmeticResult(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	tests := []struct {
+		input     string
+		unchecked interface{}
+		checked   interface{}
+	}{
+		{"9223372036854775807 + 1", bigInt("9223372036854775808"), "integer overflow: 9223372036854775807 + 1"},
+		{"-9223372036854775807 - 2", bigInt("-9223372036854775809"), "integer overflow: -9223372036854775807 - 2"},
+		{"4611686018427387904 * 2", bigInt("9223372036854775808"), "integer overflow: 4611686018427387904 * 2"},
+		{"-1 * (-9223372036854775807 - 1)", bigInt("9223372036854775808"), "integer overflow: -1 * -9223372036854775808"},
+		{"(-9223372036854775807 - 1) * -1", bigInt("9223372036854775808"), "integer overflow: -9223372036854775808 * -1"},
+		{"(-9223372036854775807 - 1) / -1", bigInt("9223372036854775808"), "integer overflow: -9223372036854775808 / -1"},
+		{"let x = 9223372036854775807; x += 1; x", bigInt("9223372036854775808"), "integer overflow: 9223372036854775807 + 1"},
+		{"9223372036854775806 + 1", 9223372036854775807, 9223372036854775807},
+		{"-9223372036854775807 - 1", -9223372036854775808, -9223372036854775808},
+		{"3037000499 * 3037000499", 9223372030926249001, 9223372030926249001},
+		{"-3037000499 * 3037000499", -9223372030926249001, -9223372030926249001},
+		{"0 * (-9223372036854775807 - 1)", 0, 0},
+		{"9223372036854775807 * -1", -9223372036854775807, -9223372036854775807},
+		{"-(-9223372036854775807 - 1)", bigInt("9223372036854775808"), "integer overflow: -(-9223372036854775808)"},
+		{"9223372036854775808 - 1", 9223372036854775807, 9223372036854775807},
+		{"9223372036854775808 * 2", bigInt("18446744073709551616"), "integer overflow: 9223372036854775808 * 2"},
+		{"2 ** 63", bigInt("9223372036854775808"), "integer overflow: 2 ** 63"},
+		{"1 << 63", bigInt("9223372036854775808"), "integer overflow: 1 << 63"},
+		{"-1 << 63", -9223372036854775808, -9223372036854775808},
+		{"(-2) ** 63", -9223372036854775808, -9223372036854775808},
+		{"(-9223372036854775807 - 1) % -1", 0, 0},
+	}
+
+	for _, tt := range tests {
+		testArithmeticResult(t, tt.input, testEval(tt.input), tt.unchecked)
+		evaluated := testEvalChecked(tt.input)
+		testArithmeticResult(t, tt.input, evaluated, tt.checked)
+		if err, ok := evaluated.(*object.Error); ok && err.Kind != object.OverflowError {
+			t.Errorf("%s: wrong error kind. got=%q", tt.input, err.Kind)
+		}
+	}
+}
+
+// 大整数的运算结果能用int64表示时转回Integer 比较结果的类型和Inspect
+func TestBigIntArithmetic(t *testing.T) {
+	tests := []struct {
+		input        string
+		expectedType object.ObjectType
+		expected     string
+	}{
+		{"9223372036854775808", object.BIGINT_OBJ, "9223372036854775808"},
+		{"-9223372036854775808", object.INTEGER_OBJ, "-9223372036854775808"},
+		{"9223372036854775807 + 1 - 1", object.INTEGER_OBJ, "9223372036854775807"},
+		{"let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } }; fact(25)", object.BIGINT_OBJ, "15511210043330985984000000"},
+		{"let fact = fn(n) { if (n == 0) { 1 } else { n * fact(n - 1) } }; fact(25) / fact(23)", object.INTEGER_OBJ, "600"},
+		{"18446744073709551616 - 18446744073709551615", object.INTEGER_OBJ, "1"},
+		{"-18446744073709551616 / 3", object.INTEGER_OBJ, "-6148914691236517205"}, // 和int64一样向0取整
+		{"-36893488147419103232 / 3", object.BIGINT_OBJ, "-12297829382473034410"},
+		{"18446744073709551616 / 0", object.ERROR_OBJ, "ERROR: 1:22: division by zero"},
+		{"-9223372036854775809", object.BIGINT_OBJ, "-9223372036854775809"},
+		{"9223372036854775808 > 9223372036854775807", object.BOOLEAN_OBJ, "true"},
+		{"-9223372036854775809 < -9223372036854775807 - 1", object.BOOLEAN_OBJ, "true"},
+		{"9223372036854775808 == 9223372036854775807 + 1", object.BOOLEAN_OBJ, "true"},
+		{"9223372036854775808 != 9223372036854775808", object.BOOLEAN_OBJ, "false"},
+		{"9223372036854775808 >= 1", object.BOOLEAN_OBJ, "true"},
+		{"9223372036854775808 == 9223372036854775808.0", object.BOOLEAN_OBJ, "true"},
+		{"9223372036854775808 < 1e19", object.BOOLEAN_OBJ, "true"},
+		{"18446744073709551616 * 0.5", object.FLOAT_OBJ, "9223372036854776000.0"},
+		{"~9223372036854775808", object.BIGINT_OBJ, "-9223372036854775809"},
+		{"9223372036854775808 & 0xff", object.INTEGER_OBJ, "0"},
+		{"9223372036854775808 | 1", object.BIGINT_OBJ, "9223372036854775809"},
+		{"(9223372036854775808 + 5) ^ 9223372036854775808", object.INTEGER_OBJ, "5"},
+		{"9223372036854775808 << 1", object.BIGINT_OBJ, "18446744073709551616"},
+		{"1 << 64", object.BIGINT_OBJ, "18446744073709551616"},
+		{"1 << 63", object.BIGINT_OBJ, "9223372036854775808"},
+		{"-1 << 63", object.INTEGER_OBJ, "-9223372036854775808"},
+		{"3 << 62", object.BIGINT_OBJ, "13835058055282163712"},
+		{"18446744073709551616 >> 2", object.INTEGER_OBJ, "4611686018427387904"},
+		{"-18446744073709551617 >> 1", object.BIGINT_OBJ, "-9223372036854775809"},
+		{"18446744073709551616 >> 18446744073709551616", object.INTEGER_OBJ, "0"},
+		{"-18446744073709551616 >> 100", object.INTEGER_OBJ, "-1"},
+		{"1 << 18446744073709551616", object.ERROR_OBJ, "ERROR: 1:3: shift count too large: 1 << 18446744073709551616"},
+		{"0 << 18446744073709551616", object.INTEGER_OBJ, "0"},
+		{"9223372036854775808 + true", object.ERROR_OBJ, "ERROR: 1:21: type mismatch: BIGINT + BOOLEAN"},
+		{"[9223372036854775808, -9223372036854775809]", object.ARRAY_OBJ, "[9223372036854775808, -9223372036854775809]"},
+		{"[1, 2][9223372036854775808]", object.NULL_OBJ, "null"},
+		{`"abc"[-9223372036854775809]`, object.NULL_OBJ, "null"},
+		{"let a = [1]; a[9223372036854775808] = 2", object.ERROR_OBJ, "ERROR: 1:37: index out of range: 9223372036854775808, array length is 1"},
+		{`{9223372036854775808: "a"}[9223372036854775807 + 1]`, object.STRING_OBJ, "a"},
+		{`{100000000000000000000: "a"}[1e20]`, object.STRING_OBJ, "a"},
+		{`let r = []; for (k in {9223372036854775808: 1, 1: 2, -9223372036854775809: 3, 2.5: 4}) { r = push(r, k) }; r`,
+			object.ARRAY_OBJ, "[-9223372036854775809, 1, 2.5, 9223372036854775808]"},
+		{"let x = 9223372036854775807; x += 1; x", object.BIGINT_OBJ, "9223372036854775808"},
+		{"quote(unquote(9223372036854775807 + 1))", object.QUOTE_OBJ, "QUOTE(9223372036854775808)"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Type() != tt.expectedType || evaluated.Inspect() != tt.expected {
+			t.Errorf("%s: expected %s %s, got %s %s", tt.input, tt.expectedType, tt.expected, evaluated.Type(), evaluated.Inspect())
+		}
+	}
+}
+
+// 符号约定：除法向0取整 余数的符号和被除数相同 所以 a == a / b * b + a % b 总是成立
+// 这和Go C Java一致 和Python不同(Python中 -7 % 3 是 2)
+func TestModuloOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"7 % -3", 1},
+		{"-7 % -3", -1},
+		{"6 % 3", 0},
+		{"-7 / 3 * 3 + -7 % 3", -7},
+		{"(-9223372036854775807 - 1) % -1", 0},
+		{"let isEven = fn(x) { x % 2 == 0 }; isEven(-4)", true},
+		{"10 - 7 % 4 * 2", 4},
+		{"let x = 17; x %= 5; x", 2},
+		{"18446744073709551617 % 10", 7},
+		{"-18446744073709551617 % 10", -7},
+		{"18446744073709551616 % 18446744073709551615", 1},
+		{"36893488147419103232 % 36893488147419103231", 1},
+		{"7.5 % 2", 1.5},
+		{"-7.5 % 2", -1.5},
+		{"7 % 2.5", 2.0},
+		{"5 % 0", "modulo by zero"},
+		{"18446744073709551616 % 0", "modulo by zero"},
+		{`"a" % 2`, "type mismatch: STRING % INTEGER"},
+		{"true % true", "unknown operator: BOOLEAN % BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		testArithmeticResult(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+// 整数的幂是整数 超出int64范围时是大整数 指数是负数时结果是浮点数
+// ** 是右结合的 优先级高于 * 但低于前缀运算符 -2 ** 2 是 (-2) ** 2
+func TestPowerOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"2 ** 10", 1024},
+		{"2 ** 0", 1},
+		{"0 ** 0", 1},
+		{"0 ** 5", 0},
+		{"(-2) ** 3", -8},
+		{"-2 ** 2", 4},
+		{"-(2 ** 2)", -4},
+		{"2 ** 3 ** 2", 512},
+		{"(2 ** 3) ** 2", 64},
+		{"3 * 2 ** 2", 12},
+		{"2 ** 62 + (2 ** 62 - 1)", 9223372036854775807},
+		{"2 ** 63", bigInt("9223372036854775808")},
+		{"(-2) ** 63", -9223372036854775808},
+		{"10 ** 30 / 10 ** 28", 100},
+		{"(2 ** 64) ** 2", bigInt("340282366920938463463374607431768211456")},
+		{"1 ** 100000000000", 1},
+		{"(-1) ** 100000000001", -1},
+		{"2 ** -1", 0.5},
+		{"(-2) ** -2", 0.25},
+		{"2.0 ** 3", 8.0},
+		{"4 ** 0.5", 2.0},
+		{"let x = 3; x **= 2; x", 9},
+		{"0 ** -1", "zero to a negative power: 0 ** -1"},
+		{"2 ** 100000000", "exponent too large: 2 ** 100000000"},
+		{"2 ** 18446744073709551616", "exponent too large: 2 ** 18446744073709551616"},
+		{`"a" ** 2`, "type mismatch: STRING ** INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testArithmeticResult(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+// 期望的结果是整数 布尔值 或者错误信息
+func testArithmeticResult(t *testing.T, input string, obj object.Object, expected interface{}) {
+	t.Helper()
+	switch expected := expected.(type) {
+	case int:
+		testIntegerObject(t, obj, int64(expected))
+	case bigInt:
+		testBigIntObject(t, obj, string(expected))
+	case float64:
+		testFloatObject(t, obj, expected)
+	case bool:
+		testBooleanObject(t, obj, expected)
+	case string:
+		errObj, ok := obj.(*object.Error)
+		if !ok {
+			t.Errorf("%s: object is not Error. got=%T (%+v)", input, obj, obj)
+			return
+		}
+		if errObj.Message != expected {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", input, expected, errObj.Message)
+		}
+	}
+}
+
+func TestBitwiseOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"12 & 10", 8},
+		{"12 | 10", 14},
+		{"12 ^ 10", 6},
+		{"~0", -1},
+		{"~5", -6},
+		{"1 << 10", 1024},
+		{"1024 >> 3", 128},
+		{"-16 >> 2", -4}, // 算术右移 保留符号
+		{"-1 << 63", -9223372036854775808},
+		{"0xff & 0x0f | 0x100", 0x10f},
+		{"1 | 2 == 2", "type mismatch: INTEGER | BOOLEAN"}, // == 的优先级高于 | 和C语言一致
+		{"(1 | 2) == 3", true},
+		{"1 << -1", "shift count out of range: -1"},
+		{"1 >> 64", 0}, // 所有的位都被移出
+		{"-1 >> 64", -1},
+		{"-5 >> 1", -3}, // 向负无穷取整
+		{"1.5 & 1", "unknown operator: FLOAT & INTEGER"},
+		{"~1.5", "unknown operator: ~FLOAT"},
+		{"~true", "unknown operator: ~BOOLEAN"},
+		{"true | false", "unknown operator: BOOLEAN | BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestLogicalOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"true && true", true},
+		{"true && false", false},
+		{"false || true", true},
+		{"false || false", false},
+		// 结果是决定结果的那个操作数 不一定是布尔值
+		{"1 && 2", 2},
+		{"0 || 3", 0}, // 0 是真值
+		{"false || 7", 7},
+		{"if (false) { 1 } && 2", nil},
+		{"if (false) { 1 } || 5", 5},
+		// 短路 右侧不会被求值
+		{"false && undefined", false},
+		{"true || undefined", true},
+		{"false && (1 + true)", false},
+		{"true && (1 + true)", "type mismatch: INTEGER + BOOLEAN"},
+		{"false || missing", "identifier not found: missing"},
+		{"false && len(1)", false}, // 右侧的函数调用不会执行
+		{"let x = 5; x > 1 && x < 10", true},
+		{"let x = 5; x < 1 || x > 10", false},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case nil:
+			testNullObject(t, evaluated)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; x = x + 1", 2}, // 赋值表达式的值就是赋的值
+		{"let x = 1; let y = 1; x = y = 5; x + y", 10},
+		{"let x = 10; x += 5; x", 15},
+		{"let x = 10; x -= 5; x", 5},
+		{"let x = 10; x *= 5; x", 50},
+		{"let x = 10; x /= 5; x", 2},
+		// 闭包修改外层作用域的绑定
+		{"let count = 0; let inc = fn() { count += 1 }; inc(); inc(); count", 2},
+		{`let counter = fn() { let n = 0; fn() { n = n + 1; n } };
+		  let c = counter(); c(); c(); c()`, 3},
+		{`let counter = fn() { let n = 0; fn() { n = n + 1; n } };
+		  let a = counter(); let b = counter(); a(); a(); b()`, 1},
+		// 函数内的let定义新的局部绑定 不会修改外层的绑定
+		{"let x = 1; let f = fn() { let x = 2; x = 3 }; f(); x", 1},
+		{"let f = fn(x) { x = x * 2; x }; f(4)", 8},
+		// 数组和hash的元素原地修改
+		{"let a = [1, 2, 3]; a[1] = 5; a[1]", 5},
+		{"let a = [1, 2, 3]; a[2] += 10; a[2]", 13},
+		{"let a = [1, 2]; let b = a; b[0] = 9; a[0]", 9},
+		{`let h = {"a": 1}; h["a"] = 2; h["a"]`, 2},
+		{`let h = {}; h["b"] = 3; h["b"]`, 3},
+		{`let h = {"n": 1}; h["n"] *= 7; h["n"]`, 7},
+		{"let m = [[1, 2], [3, 4]]; m[1][0] = 8; m[1][0]", 8},
+		{"let a = [1]; let f = fn() { a[0] = 2 }; f(); a[0]", 2},
+		// 错误
+		{"y = 1", "identifier not found: y"},
+		{"let f = fn() { z = 1 }; f()", "identifier not found: z"},
+		{"len = 1", "cannot assign to builtin len"},
+		{"let x = 1; x += true", "type mismatch: INTEGER + BOOLEAN"},
+		{"let a = [1]; a[1] = 2", "index out of range: 1, array length is 1"},
+		{"let a = [1]; a[-1] = 2", "index out of range: -1, array length is 1"},
+		{"let h = {}; h[fn() {}] = 1", "unusable as hash key: FUNCTION"},
+		{"let s = \"abc\"; s[0] = \"x\"", "index assignment not supported: STRING[INTEGER]"},
+		{"let h = {}; h[\"k\"] += 1", "type mismatch: NULL + INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+
+	str, ok := testEval(`let s = "a"; s += "b"; s`).(*object.String)
+	if !ok || str.Value != "ab" {
+		t.Errorf("string compound assignment wrong. got=%+v", str)
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"let i = 0; while (i < 5) { i += 1 }; i", 5},
+		{"let i = 0; while (false) { i = 1 }; i", 0},
+		{"let i = 0; while (true) { i += 1; if (i == 3) { break } }; i", 3},
+		{"let i = 0; let odd = 0; while (i < 10) { i += 1; if ((i & 1) == 0) { continue }; odd += 1 }; odd", 5},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x }; sum", 6},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 3) { break }; sum += x }; sum", 3},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue } sum += x }; sum", 8},
+		{"for (x in [1, 2, 3]) { }; x", 3}, // 循环变量在循环结束后仍然可以访问
+		{"let n = 0; for (x in []) { n += 1 }; n", 0},
+		{`let sum = 0; for (k in {1: "a", 2: "b", 3: "c"}) { sum += k }; sum`, 6},
+		{`let n = 0; for (c in "中文ab") { n += 1 }; n`, 4},
+		// 嵌套的循环 break只跳出最内层
+		{`
+		let count = 0;
+		for (i in [1, 2, 3]) {
+			let j = 0;
+			while (true) {
+				j += 1;
+				if (j > i) { break }
+				count += 1;
+			}
+		};
+		count`, 6},
+		// return跳出循环和函数
+		{"let find = fn(arr, v) { for (x in arr) { if (x == v) { return true } }; false }; find([1, 2], 2)", true},
+		{"let f = fn() { let i = 0; while (true) { i += 1; if (i == 4) { return i * 10 } } }; f()", 40},
+		// 不需要递归也可以循环很多次
+		{"let i = 0; while (i < 100000) { i += 1 }; i", 100000},
+		{"for (x in 1) { }", "cannot iterate over INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("%s: wrong error message. expected=%q, got=%q", tt.input, expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestForLoopOrder(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`let out = ""; for (c in "a中b") { out = c + out }; out`, "b中a"},
+		// hash按照排好序的键访问
+		{`let out = ""; for (k in {"b": 1, "c": 2, "a": 3}) { out += k }; out`, "abc"},
+		{`let h = {"x": 1, "y": 2}; let out = ""; for (k in h) { out += k; h[k] = 0 }; out`, "xy"},
+	}
+
+	for _, tt := range tests {
+		str, ok := testEval(tt.input).(*object.String)
+		if !ok || str.Value != tt.expected {
+			t.Errorf("%s: expected %q, got %+v", tt.input, tt.expected, str)
+		}
+	}
+}
+
+// 字符串按内容比较 < > 按字节的字典序
+func TestStringComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"a" == "a"`, true},
+		{`"a" + "b" == "ab"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{`"a" != "b"`, true},
+		{`"" == ""`, true},
+		{`"a" < "b"`, true},
+		{`"abc" < "abd"`, true},
+		{`"ab" < "abc"`, true},
+		{`"b" > "abc"`, true},
+		{`"a" > "a"`, false},
+		{`"Z" < "a"`, true}, // 按字节比较 大写字母在前
+		{`"中" > "a"`, true},
+		{`let s = "x"; let t = "x"; s == t`, true},
+		{`"1" == 1`, false},
+		{`"a" < 1`, "type mismatch: STRING < INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testArithmeticResult(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestStringRepetition(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"ab" * 3`, "ababab"},
+		{`3 * "ab"`, "ababab"},
+		{`"ab" * 1`, "ab"},
+		{`"ab" * 0`, ""},
+		{`"ab" * -2`, ""},
+		{`"" * 100`, ""},
+		{`"中" * 2`, "中中"},
+		{`"-" * 2 + "|"`, "--|"},
+		{`"" * 18446744073709551616`, ""},
+		{`"a" * -18446744073709551616`, ""},
+		{`let s = "ab"; s *= 2; s`, "abab"},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, tt.input, testEval(tt.input), tt.expected)
+	}
+
+	errors := []struct {
+		input    string
+		expected string
+	}{
+		{`"ab" * 1000000000`, "repeated string too long: 2 bytes * 1000000000"},
+		{`"a" * 18446744073709551616`, "repeated string too long: 1 bytes * 18446744073709551616"},
+		{`"ab" * 1.5`, "type mismatch: STRING * FLOAT"},
+		{`"ab" * "c"`, "unknown operator: STRING * STRING"},
+		{`"ab" - 1`, "type mismatch: STRING - INTEGER"},
+	}
+
+	for _, tt := range errors {
+		testArithmeticResult(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+// in 判断子串 数组元素和hash的键
+func TestInOperator(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`"ell" in "hello"`, true},
+		{`"" in "hello"`, true},
+		{`"lo!" in "hello"`, false},
+		{`"文" in "中文"`, true},
+		{`2 in [1, 2, 3]`, true},
+		{`4 in [1, 2, 3]`, false},
+		{`2.0 in [1, 2, 3]`, true}, // 和 == 一样 整数和浮点数按数值比较
+		{`"b" in ["a", "b"]`, true},
+		{`"1" in [1]`, false},
+		{`let n = if (false) { 1 }; n in [1, n]`, true},
+		{`[1] in [[1]]`, false}, // 数组按对象比较
+		{`let a = [1]; a in [a]`, true},
+		{`9223372036854775808 in [9223372036854775807 + 1]`, true},
+		{`1 in []`, false},
+		{`"a" in {"a": 1}`, true},
+		{`"b" in {"a": 1}`, false},
+		{`1 in {1.0: "x"}`, true},
+		{`true in {true: 1}`, true},
+		{`let h = {"a": if (false) { 1 }}; "a" in h`, true}, // 值是null也算有这个键
+		{`"a" in "abc" == true`, true},
+		{`1 + 1 in [2]`, true},
+		{`!("x" in "abc")`, true},
+		{`1 in "abc"`, "type mismatch: INTEGER in STRING"},
+		{`[1] in {}`, "unusable as hash key: ARRAY"},
+		{`1 in 1`, "unknown operator: INTEGER in INTEGER"},
+	}
+
+	for _, tt := range tests {
+		testArithmeticResult(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestLessEqualGreaterEqual(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"1.5 >= 1", true},
+		{"1 <= 0.5", false},
+		{`"a" <= "b"`, true},
+		{`"abc" <= "abc"`, true},
+		{`"b" >= "abc"`, true},
+		{`"B" >= "a"`, false}, // 按字节比较 大写字母在前
+		{`"" <= "a"`, true},
+		{"true <= false", "unknown operator: BOOLEAN <= BOOLEAN"},
+		{`"a" >= 1`, "type mismatch: STRING >= INTEGER"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		switch expected := tt.expected.(type) {
+		case bool:
+			testBooleanObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14", 3.14},
+		{"-2.5", -2.5},
+		{"1e-9", 1e-9},
+		{"0.5 + 0.25", 0.75},
+		{"1 + 0.5", 1.5}, // 整数提升为浮点数
+		{"0.5 * 4", 2},
+		{"7 / 2.0", 3.5},
+		{"10 - 2.5 * 2", 5},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		testFloatObject(t, evaluated, tt.expected)
+	}
+
+	// 整数之间的除法仍然是整数
+	testIntegerObject(t, testEval("7 / 2"), 3)
+	// 浮点数除以0 按照IEEE 754得到Inf
+	if got := testEval("1 / 0.0").Inspect(); got != "+Inf" {
+		t.Errorf("1 / 0.0 wrong. got=%s", got)
+	}
+}
+
+func TestFloatComparison(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"1.5 < 2", true},
+		{"2 > 1.5", true},
+		{"1.0 == 1", true},
+		{"0.1 + 0.2 == 0.3", false},
+		{"0.5 != 0.5", false},
+	}
+
+	for _, tt := range tests {
+		testBooleanObject(t, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFloatHashKeys(t *testing.T) {
+	testIntegerObject(t, testEval(`{1: 10}[1.0]`), 10)
+	testIntegerObject(t, testEval(`{1.5: 15}[1.5]`), 15)
+	if testEval(`{1.5: 15}[1]`) != NULL {
+		t.Errorf("1 should not find the 1.5 key")
+	}
+}
+
+func TestNumberBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected interface{}
+	}{
+		{`int(3.9)`, 3},
+		{`int(-3.9)`, -3},
+		{`int(7)`, 7},
+		{`int("42")`, 42},
+		{`int(" 0x1f ")`, 31},
+		{`float(2)`, 2.0},
+		{`float("1e3")`, 1000.0},
+		{`float(0.5)`, 0.5},
+		{`floor(2.7)`, 2},
+		{`floor(-2.5)`, -3},
+		{`ceil(2.1)`, 3},
+		{`ceil(-2.5)`, -2},
+		{`round(2.5)`, 3},
+		{`round(-2.5)`, -3},
+		{`round(2.4)`, 2},
+		{`round(5)`, 5},
+		{`int("abc")`, "could not parse \"abc\" as integer"},
+		{`float("x")`, "could not parse \"x\" as float"},
+		{`int(true)`, "argument to `int` not supported, got BOOLEAN"},
+		{`floor("1")`, "argument to `floor` must be INTEGER or FLOAT, got STRING"},
+		{`round(1e20)`, bigInt("100000000000000000000")},
+		{`int(-1e19)`, bigInt("-10000000000000000000")},
+		{`int("123456789012345678901234567890")`, bigInt("123456789012345678901234567890")},
+		{`int("-0x8000_0000_0000_0000")`, -9223372036854775808},
+		{`int(9223372036854775808)`, bigInt("9223372036854775808")},
+		{`float(9223372036854775808)`, 9223372036854775808.0},
+		{`floor(100000000000000000000)`, bigInt("100000000000000000000")},
+		{`int(1 / 0.0)`, "argument to `int` out of integer range: +Inf"},
+		{`int(0.0 / 0)`, "argument to `int` out of integer range: NaN"},
+		{`ceil(1, 2)`, "wrong number of arguments. got=2, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+
+		switch expected := tt.expected.(type) {
+		case int:
+			testIntegerObject(t, evaluated, int64(expected))
+		case bigInt:
+			testBigIntObject(t, evaluated, string(expected))
+		case float64:
+			testFloatObject(t, evaluated, expected)
+		case string:
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Errorf("%s: object is not Error. got=%T (%+v)", tt.input, evaluated, evaluated)
+				continue
+			}
+			if errObj.Message != expected {
+				t.Errorf("wrong error message. expected=%q, got=%q", expected, errObj.Message)
+			}
+		}
+	}
+}
+
+func testFloatObject(t *testing.T, obj object.Object, expected float64) bool {
+	result, ok := obj.(*object.Float)
+	if !ok {
+		t.Errorf("object is not Float. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value != expected {
+		t.Errorf("object has wrong value. got=%g, want=%g",
+			result.Value, expected)
+		return false
+	}
+
+	return true
+}
+
 func testEval(input string) object.Object {
 	l := lexer.New(input)
 	p := parser.New(l)
@@ -514,6 +1560,32 @@ func testEval(input string) object.Object {
 	return Eval(program, env)
 }
 
+// 检查整数运算溢出的求值 设置只影响这次求值
+func testEvalChecked(input string) object.Object {
+	l := lexer.New(input)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	env := object.NewEnvironment()
+	env.CallStack().CheckedArithmetic = true
+	return Eval(program, env)
+}
+
+// 期望的大整数 十进制形式
+type bigInt string
+
+func testBigIntObject(t *testing.T, obj object.Object, expected string) bool {
+	result, ok := obj.(*object.BigInt)
+	if !ok {
+		t.Errorf("object is not BigInt. got=%T (%+v)", obj, obj)
+		return false
+	}
+	if result.Value.String() != expected {
+		t.Errorf("object has wrong value. got=%s, want=%s", result.Value, expected)
+		return false
+	}
+	return true
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -0,0 +1,95 @@
+package evaluator
+
+import (
+	"monkey/ast"
+	"monkey/object"
+)
+
+// throw 语句的值转换成错误 错误和运行时错误一样向外传递 直到被try捕获
+// 字符串作为错误信息 catch得到的hash可以再次抛出 保留原来的信息和种类 其他的值使用Inspect()作为信息
+func ThrowValue(val object.Object) *object.Error {
+	switch val := val.(type) {
+	case *object.Error:
+		return val
+	case *object.String:
+		return &object.Error{Message: val.Value}
+	case *object.Hash:
+		err := &object.Error{Message: val.Inspect()}
+		if message, ok := hashField(val, "message"); ok {
+			err.Message = message.Inspect()
+		}
+		if kind, ok := hashField(val, "kind"); ok {
+			if kind, ok := kind.(*object.String); ok {
+				err.Kind = kind.Value
+			}
+		}
+		return err
+	default:
+		return &object.Error{Message: val.Inspect()}
+	}
+}
+
+// catch绑定的值 包括错误信息 种类 和错误经过的调用 最内层的调用在前
+// {"message": "...", "kind": "Error", "trace": ["f(1 arg) called at 3:1"]}
+func ErrorHash(err *object.Error) *object.Hash {
+	kind := err.Kind
+	if kind == "" {
+		kind = object.DefaultErrorKind
+	}
+	trace := make([]object.Object, len(err.Trace))
+	for i, frame := range err.Trace {
+		trace[i] = &object.String{Value: frame.String()}
+	}
+	hash := &object.Hash{Pairs: make(map[object.HashKey]object.HashPair)}
+	for _, field := range []struct {
+		key   string
+		value object.Object
+	}{
+		{"message", &object.String{Value: err.Message}},
+		{"kind", &object.String{Value: kind}},
+		{"trace", &object.Array{Elements: trace}},
+	} {
+		key := &object.String{Value: field.key}
+		hash.Pairs[key.HashKey()] = object.HashPair{Key: key, Value: field.value}
+	}
+	return hash
+}
+
+func hashField(hash *object.Hash, name string) (object.Object, bool) {
+	pair, ok := hash.Pairs[(&object.String{Value: name}).HashKey()]
+	return pair.Value, ok
+}
+
+// try表达式 try块出错时执行catch块 catch绑定的名称和循环变量一样定义在当前环境中
+// finally块总是执行 它的值被丢弃 但是其中的return break和错误会代替原来的结果
+func evalTryExpression(node *ast.TryExpression, env *object.Environment) object.Object {
+	result := evalTryBlock(node.Block, env)
+	if err, ok := result.(*object.Error); ok && node.Catch != nil {
+		env.Set(node.Parameter.Value, ErrorHash(err))
+		result = evalTryBlock(node.Catch, env)
+	}
+	if node.Finally != nil {
+		final := Eval(node.Finally, env)
+		switch final.(type) {
+		case *object.Error, *object.ReturnValue, *object.Break, *object.Continue:
+			return final
+		}
+	}
+	return result
+}
+
+// try和catch块中return的函数调用不是尾调用 要在离开try之前执行
+// 这样调用中的错误才能被捕获 finally也在调用结束之后执行
+func evalTryBlock(block *ast.BlockStatement, env *object.Environment) object.Object {
+	result := Eval(block, env)
+	if rv, ok := result.(*object.ReturnValue); ok {
+		if _, ok := rv.Value.(*tailCall); ok {
+			value := applyFunction(rv.Value)
+			if _, ok := value.(*object.Error); ok {
+				return value
+			}
+			return &object.ReturnValue{Value: value}
+		}
+	}
+	return result
+}
@@ -0,0 +1,136 @@
+package evaluator
+
+import (
+	"monkey/object"
+	"testing"
+)
+
+func TestTryCatch(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { throw "bad"; 1 } catch (e) { e["message"] }`, "bad"},
+		{`try { throw "bad" } catch (e) { e["kind"] }`, "Error"},
+		{`try { throw 42 } catch (e) { e["message"] }`, "42"},
+		{`try { throw {"message": "m", "kind": "ValueError"} } catch (e) { e["kind"] + ": " + e["message"] }`, "ValueError: m"},
+		// 运行时错误和内置函数的错误都可以捕获
+		{`try { 1 + true } catch (e) { e["message"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { len(1) } catch (e) { e["message"] }`, "argument to `len` not supported, got INTEGER"},
+		{`try { foo } catch (e) { e["message"] }`, "identifier not found: foo"},
+		{`let f = fn() { throw "inner" }; try { f(); 1 } catch (e) { e["message"] }`, "inner"},
+		// catch的变量定义在当前环境中
+		{`try { throw "x" } catch (e) { 1 }; e["message"]`, "x"},
+		// 再次抛出
+		{`try { try { throw "a" } catch (e) { throw e } } catch (e) { e["message"] }`, "a"},
+		{`try { try { throw "a" } catch (e) { throw "b" } } catch (e) { e["message"] }`, "b"},
+		{`let x = try { throw "a" } catch (e) { 5 }; x * 2`, 10},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestFinally(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let log = []; try { log = push(log, 1) } finally { log = push(log, 2) }; log`, []int{1, 2}},
+		{`let log = []; try { throw "x" } catch (e) { log = push(log, 1) } finally { log = push(log, 2) }; log`, []int{1, 2}},
+		{`try { 1 } catch (e) { 2 } finally { 3 }`, 1}, // finally的值被丢弃
+		// 没有catch时执行finally之后错误继续传递
+		{`let n = 0; let r = try { try { throw "x" } finally { n = 1 } } catch (e) { e["message"] }; [r, n]`, []any{"x", 1}},
+		// catch中的错误也要执行finally
+		{`let n = 0; let r = try { try { throw "x" } catch (e) { throw "y" } finally { n = 1 } } catch (e) { e["message"] }; [r, n]`, []any{"y", 1}},
+		// finally中的return代替原来的结果
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{`let f = fn() { try { throw "x" } finally { return 2 } }; f()`, 2},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+// try中的return仍然从函数返回 break和continue仍然作用于循环 经过finally时执行finally
+func TestTryControlFlow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected any
+	}{
+		{`let f = fn() { try { return 1; 2 } catch (e) { 3 }; 4 }; f()`, 1},
+		{`let f = fn() { try { throw "x" } catch (e) { return 3 }; 4 }; f()`, 3},
+		{`let n = 0; let f = fn() { try { return 1 } finally { n = 5 } }; [f(), n]`, []int{1, 5}},
+		{`let out = []; for (i in [1, 2, 3, 4]) { try { if (i == 2) { continue } if (i == 4) { break } out = push(out, i) } finally { out = push(out, 0) } } out`, []int{1, 0, 0, 3, 0, 0}},
+		{`let out = []; for (i in [1, 2, 3]) { try { if (i == 2) { throw "skip" } out = push(out, i) } catch (e) { continue } } out`, []int{1, 3}},
+		// return的调用在try中执行 调用中的错误可以被捕获
+		{`let g = fn() { throw "g" }; let f = fn() { try { return g() } catch (e) { return e["message"] } }; f()`, "g"},
+		{`let log = []; let g = fn() { log = push(log, "g"); 1 }; let f = fn() { try { return g() } finally { log = push(log, "finally") } }; f(); log`, []any{"g", "finally"}},
+	}
+
+	for _, tt := range tests {
+		testExpected(t, tt.input, testEval(tt.input), tt.expected)
+	}
+}
+
+func TestUncaughtThrow(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`throw "boom"`, "ERROR: 1:1: boom"},
+		{"let f = fn() {\n  throw [1, 2];\n};\nf()", "ERROR: 2:3: [1, 2]"},
+		{`try { throw "a" } finally { 1 }`, "ERROR: 1:7: a"},
+	}
+
+	for _, tt := range tests {
+		if got := testEval(tt.input).Inspect(); got != tt.expected {
+			t.Errorf("%s: want=%q, got=%q", tt.input, tt.expected, got)
+		}
+	}
+}
+
+func TestCaughtTrace(t *testing.T) {
+	input := `
+let inner = fn(x) { throw "deep" };
+let outer = fn() { let r = inner(1); r };
+try { outer(); 0 } catch (e) { e["trace"] }`
+	testExpected(t, input, testEval(input), []any{"inner(1 arg) called at 3:33", "outer(0 args) called at 4:12"})
+}
+
+// 比较求值的结果 期望值可以是整数 布尔值 字符串以及它们的数组
+func testExpected(t *testing.T, input string, obj object.Object, expected any) {
+	t.Helper()
+	switch expected := expected.(type) {
+	case int:
+		if result, ok := obj.(*object.Integer); !ok || result.Value != int64(expected) {
+			t.Errorf("%s: object is not Integer %d. got=%T (%+v)", input, expected, obj, obj)
+		}
+	case bool:
+		if result, ok := obj.(*object.Boolean); !ok || result.Value != expected {
+			t.Errorf("%s: object is not Boolean %t. got=%T (%+v)", input, expected, obj, obj)
+		}
+	case string:
+		if result, ok := obj.(*object.String); !ok || result.Value != expected {
+			t.Errorf("%s: object is not String %q. got=%T (%+v)", input, expected, obj, obj)
+		}
+	case []int:
+		elements := make([]any, len(expected))
+		for i, el := range expected {
+			elements[i] = el
+		}
+		testExpected(t, input, obj, elements)
+	case []any:
+		array, ok := obj.(*object.Array)
+		if !ok || len(array.Elements) != len(expected) {
+			t.Errorf("%s: object is not Array of %d elements. got=%T (%+v)", input, len(expected), obj, obj)
+			return
+		}
+		for i, el := range expected {
+			testExpected(t, input, array.Elements[i], el)
+		}
+	}
+}
@@ -10,16 +10,20 @@ import (
 // quote函数 只能有一个参数 不对参数求值
 func quote(node ast.Node, env *object.Environment) object.Object {
 	// 遇到需要处理的node
-	node = evalUnquoteCalls(node, env)
+	node, err := evalUnquoteCalls(node, env)
+	if err != nil {
+		return err
+	}
 	return &object.Quote{
 		Node: node,
 	}
 }
 
-// 处理ast 需要进行求值的ast就会求值
-func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
-	return ast.Modify(quoted, func(node ast.Node) ast.Node {
-		if !isUnquoteCall(node) {
+// 处理ast 需要进行求值的ast就会求值 返回第一个unquote中的错误
+func evalUnquoteCalls(quoted ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	var firstErr *object.Error
+	node := ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if firstErr != nil || !isUnquoteCall(node) {
 			return node // 不是uniquote调用
 		}
 		// uniquote调用 需要对参数求值的
@@ -31,12 +35,22 @@ func evalUnquoteCalls(quoted ast.Node, env *object.Environment) ast.Node {
 			return node // 参数不是1
 		}
 		unquoted := Eval(call.Arguments[0], env)
-		return convertObjectToASTNode(unquoted)
+		if err, ok := unquoted.(*object.Error); ok {
+			firstErr = err
+			return node
+		}
+		converted, err := convertObjectToASTNode(unquoted)
+		if err != nil {
+			firstErr = withPos(err, call).(*object.Error)
+			return node
+		}
+		return converted
 	})
+	return node, firstErr
 }
 
-// 转换 object为node
-func convertObjectToASTNode(obj object.Object) ast.Node {
+// 转换 object为node 不能表示为字面量的对象返回错误
+func convertObjectToASTNode(obj object.Object) (ast.Node, *object.Error) {
 	switch obj := obj.(type) {
 	case *object.Integer:
 		t := token.Token{
@@ -46,7 +60,25 @@ func convertObjectToASTNode(obj object.Object) ast.Node {
 		return &ast.IntegerLiteral{
 			Token: t,
 			Value: obj.Value,
+		}, nil
+	case *object.BigInt:
+		t := token.Token{
+			Type:    token.INT,
+			Literal: obj.Value.String(),
+		}
+		return &ast.BigIntLiteral{
+			Token: t,
+			Value: obj.Value,
+		}, nil
+	case *object.Float:
+		t := token.Token{
+			Type:    token.FLOAT,
+			Literal: obj.Inspect(),
 		}
+		return &ast.FloatLiteral{
+			Token: t,
+			Value: obj.Value,
+		}, nil
 	case *object.Boolean:
 		var t token.Token
 		if obj.Value {
@@ -63,12 +95,11 @@ func convertObjectToASTNode(obj object.Object) ast.Node {
 		return &ast.Boolean{
 			Token: t,
 			Value: obj.Value,
-		}
+		}, nil
 	case *object.Quote:
-		return obj.Node // unique(quote(node)) 嵌套quote 那么被嵌套的quote已经处理过node 这里不要二次处理！
-		// TODO 错误处理？
+		return obj.Node, nil // unique(quote(node)) 嵌套quote 那么被嵌套的quote已经处理过node 这里不要二次处理！
 	default:
-		return nil
+		return nil, newError(object.TypeError, "cannot unquote %s: not representable as a literal", obj.Type())
 	}
 }
 
@@ -44,6 +44,14 @@ func TestQuoteUnquote(t *testing.T) {
 			`quote(unquote(true == false))`,
 			`false`,
 		},
+		{
+			`quote(unquote(1.5 + 1))`,
+			`2.5`,
+		},
+		{
+			`quote(1 + unquote(2 ** 64))`,
+			`(1 + 18446744073709551616)`,
+		},
 		{
 			`quote(unquote(quote(4 + 4)))`,
 			`(4 + 4)`,
@@ -73,6 +81,24 @@ func TestQuoteUnquote(t *testing.T) {
 		}
 	}
 }
+func TestUnquoteErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`quote(unquote("a"))`, "ERROR: 1:14: cannot unquote STRING: not representable as a literal"},
+		{`quote(1 + unquote([1]))`, "ERROR: 1:18: cannot unquote ARRAY: not representable as a literal"},
+		{`quote(unquote(1 + true))`, "ERROR: 1:17: type mismatch: INTEGER + BOOLEAN"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(tt.input)
+		if evaluated.Inspect() != tt.expected {
+			t.Errorf("wrong result for %q. got=%q, want=%q", tt.input, evaluated.Inspect(), tt.expected)
+		}
+	}
+}
+
 func TestQuote(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -1,21 +1,50 @@
 package lexer
 
 import (
+	"fmt"
 	"monkey/token"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
-// TODO 1. 支持utf-8 2. 支持如表情字符？ ch应该使用rune了
-
-// 词法解析
+// 词法解析 源码按utf-8解码为rune逐个处理
 type Lexer struct {
 	input        string // 源代码
-	position     int    // 当前正在查看的位置 指向当前查看字符的下标
-	readPosition int    // 当前字符的下一个字符所在的位置 下标
-	ch           byte   // 当前正在查看的字符
+	position     int    // 当前正在查看的位置 指向当前查看字符的字节下标
+	readPosition int    // 当前字符的下一个字符所在的位置 字节下标
+	ch           rune   // 当前正在查看的字符
+	filename     string // 源码所在的文件 用于错误信息
+	line         int    // 当前字符所在的行 从1开始
+	column       int    // 当前字符所在的列 从1开始 按字符计数 一个汉字是一列
+	errors       []Error
+	comments     []token.Token // 跳过的注释 按出现的顺序
+}
+
+// 词法错误的种类
+type ErrorKind int
+
+const (
+	UnterminatedString  ErrorKind = iota // 字符串没有结束
+	InvalidEscape                        // 不合法的转义字符
+	UnterminatedComment                  // 块注释没有结束
+)
+
+// 词法错误 出错时仍然会返回词法单元 解析可以继续进行
+type Error struct {
+	Kind    ErrorKind
+	Start   token.Position
+	End     token.Position // 出错区间的结束位置 不包含
+	Message string
 }
 
 func New(input string) *Lexer {
-	l := &Lexer{input: input}
+	return NewWithFilename("", input)
+}
+
+// 词法单元的位置中会记录文件名
+func NewWithFilename(filename, input string) *Lexer {
+	l := &Lexer{input: input, filename: filename, line: 1}
 	l.readChar()
 	return l
 }
@@ -23,20 +52,30 @@ func New(input string) *Lexer {
 // =================== 实现的方法 ===============
 // 读取下一个字符 readPosition指向下一个字符的位置了
 func (l *Lexer) readChar() {
-	if l.readPosition >= len(l.input) {
-		l.ch = 0 // 读取到末尾
+	if l.ch == '\n' { // 越过换行符 来到下一行的开头
+		l.line++
+		l.column = 1
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.column++
 	}
 	l.position = l.readPosition
-	l.readPosition += 1
+	if l.readPosition >= len(l.input) {
+		l.ch = 0 // 读取到末尾
+		l.readPosition = len(l.input) + 1
+		return
+	}
+	// 不合法的utf-8编码得到 utf8.RuneError 长度为1
+	ch, size := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	l.ch = ch
+	l.readPosition += size
 }
 
 // 获取下一个token
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
-	// 跳过空白字符
+	// 跳过空白字符和注释
 	l.skipWhitespace()
+	pos := l.pos() // 词法单元开始的位置
 	switch l.ch {
 	case '=':
 		// 多看一个字符 是否可以组成 ==
@@ -49,9 +88,9 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.ASSIGN, l.ch)
 		}
 	case '+':
-		tok = newToken(token.PLUS, l.ch)
+		tok = l.makeTwoCharToken('=', token.PLUS_ASSIGN, token.PLUS)
 	case '-':
-		tok = newToken(token.MINUS, l.ch)
+		tok = l.makeTwoCharToken('=', token.MINUS_ASSIGN, token.MINUS)
 	case '!':
 		if l.peekChar() == '=' {
 			ch := l.ch
@@ -62,13 +101,37 @@ func (l *Lexer) NextToken() token.Token {
 			tok = newToken(token.BANG, l.ch)
 		}
 	case '*':
-		tok = newToken(token.ASTERISK, l.ch)
-	case '/':
-		tok = newToken(token.SLASH, l.ch)
+		if l.peekChar() == '*' { // ** 和 **=
+			l.readChar()
+			tok = l.makeTwoCharToken('=', token.POWER_ASSIGN, token.POWER)
+			tok.Literal = "*" + tok.Literal
+		} else {
+			tok = l.makeTwoCharToken('=', token.ASTERISK_ASSIGN, token.ASTERISK)
+		}
+	case '%':
+		tok = l.makeTwoCharToken('=', token.PERCENT_ASSIGN, token.PERCENT)
+	case '/': // // 和 /* 开头的注释已经在skipWhitespace中跳过了
+		tok = l.makeTwoCharToken('=', token.SLASH_ASSIGN, token.SLASH)
 	case '<':
-		tok = newToken(token.LT, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.makeTwoCharToken('=', token.LT_EQ, token.LT)
+		} else {
+			tok = l.makeTwoCharToken('<', token.SHL, token.LT)
+		}
 	case '>':
-		tok = newToken(token.GT, l.ch)
+		if l.peekChar() == '=' {
+			tok = l.makeTwoCharToken('=', token.GT_EQ, token.GT)
+		} else {
+			tok = l.makeTwoCharToken('>', token.SHR, token.GT)
+		}
+	case '&':
+		tok = l.makeTwoCharToken('&', token.AND, token.BIT_AND)
+	case '|':
+		tok = l.makeTwoCharToken('|', token.OR, token.BIT_OR)
+	case '^':
+		tok = newToken(token.BIT_XOR, l.ch)
+	case '~':
+		tok = newToken(token.BIT_NOT, l.ch)
 	case ';':
 		tok = newToken(token.SEMICOLON, l.ch)
 	case ',':
@@ -89,84 +152,335 @@ func (l *Lexer) NextToken() token.Token {
 		tok = newToken(token.COLON, l.ch)
 	case '"': // string
 		tok.Type = token.STRING
-		tok.Literal = l.readString()
+		tok.Literal = l.readString(pos)
+	case '`': // 原始字符串 不处理转义 可以跨行
+		tok.Type = token.STRING
+		tok.Literal = l.readRawString(pos)
 	case 0:
-		tok.Type = token.EOF
-		tok.Literal = "" // 文件末尾了
+		if l.atEOF() {
+			tok.Type = token.EOF
+			tok.Literal = "" // 文件末尾了
+		} else { // 源码中的 \0 字符
+			tok = newToken(token.ILLEGAL, l.ch)
+		}
 	default: // 不是可直接识别的字符 检查是否是标识符
 		if isLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal) // let标识符拿到其关键字LET类型
+			tok.Pos, tok.End = pos, l.pos()
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.INT
-			tok.Literal = l.readNumber()
+			tok.Type, tok.Literal = l.readNumber()
+			tok.Pos, tok.End = pos, l.pos()
 			return tok
 		} else {
-			tok = newToken(token.ILLEGAL, l.ch) // 未知的字符
+			tok.Type = token.ILLEGAL // 未知的字符 保留源码中的原始字节
+			tok.Literal = l.input[l.position:l.readPosition]
 		}
 	}
 	l.readChar()
+	tok.Pos, tok.End = pos, l.pos()
 	return tok
 }
 
-// 读取标识符 且后移
+// 下一个字符是next时 和当前字符组成双字符的词法单元 << >> && 否则只有当前字符
+func (l *Lexer) makeTwoCharToken(next rune, twoCharType, oneCharType token.TokenType) token.Token {
+	if l.peekChar() != next {
+		return newToken(oneCharType, l.ch)
+	}
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: twoCharType, Literal: string(ch) + string(l.ch)}
+}
+
+// 当前字符的位置
+func (l *Lexer) pos() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
+}
+
+// 读取标识符 且后移 第一个字符之后可以是数字 x1 变量2
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) { // 只要是字符一直读取 start:end
+	for isLetter(l.ch) || unicode.IsDigit(l.ch) { // 只要是字母或数字一直读取 start:end
 		l.readChar()
 	}
 	return l.input[position:l.position] // position所在的字符不是letter了
 }
 
-// 跳过空白字符 也可以说"消费 吃掉"
+// 跳过空白字符 也可以说"消费 吃掉" 注释也当作空白跳过
 func (l *Lexer) skipWhitespace() {
-	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
+	for {
+		switch {
+		case l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r':
+			l.readChar()
+		case l.ch == '/' && l.peekChar() == '/':
+			l.skipLineComment()
+		case l.ch == '/' && l.peekChar() == '*':
+			l.skipBlockComment()
+		default:
+			return
+		}
+	}
+}
+
+// 行注释 // 到行尾 不包含换行符
+func (l *Lexer) skipLineComment() {
+	pos := l.pos()
+	for l.ch != '\n' && !l.atEOF() {
 		l.readChar()
 	}
+	l.addComment(pos)
 }
 
-// 读取一个数字
-func (l *Lexer) readNumber() string {
+// 块注释 /* */ 可以嵌套 /* a /* b */ c */ 是一个完整的注释
+// 方便注释掉已经含有块注释的代码
+func (l *Lexer) skipBlockComment() {
+	pos := l.pos()
+	depth := 0
+	for !l.atEOF() {
+		switch {
+		case l.ch == '/' && l.peekChar() == '*':
+			depth++
+			l.readChar()
+		case l.ch == '*' && l.peekChar() == '/':
+			depth--
+			l.readChar()
+		}
+		l.readChar()
+		if depth == 0 {
+			l.addComment(pos)
+			return
+		}
+	}
+	// 只标出开头的 /* 整个注释的区间可能很长
+	end := pos
+	end.Offset += 2
+	end.Column += 2
+	l.errors = append(l.errors, Error{Kind: UnterminatedComment, Start: pos, End: end, Message: "unterminated block comment"})
+	l.addComment(pos)
+}
+
+// 记录从pos到当前字符之前的注释
+func (l *Lexer) addComment(pos token.Position) {
+	l.comments = append(l.comments, token.Token{Type: token.COMMENT, Literal: l.input[pos.Offset:l.position], Pos: pos})
+}
+
+// 读取一个数字 有小数部分或者指数部分的是浮点数 3.14 1e-9 2.5E3
+// 0x 0o 0b 开头的是十六进制 八进制 二进制整数 数字之间可以用 _ 分隔 1_000_000
+// 这里不检查数字是否合法 0b102 会作为一个整体交给解析器报错
+func (l *Lexer) readNumber() (token.TokenType, string) {
 	position := l.position
-	for isDigit(l.ch) { // 只要是字符一直读取 start:end
+	if l.ch == '0' && isBasePrefix(l.peekChar()) {
+		l.readChar()
+		l.readChar()
+		for isLetter(l.ch) || isDigit(l.ch) {
+			l.readChar()
+		}
+		return token.INT, l.input[position:l.position]
+	}
+	var tokenType token.TokenType = token.INT
+	l.readDigits()
+	if l.ch == '.' && isDigit(l.peekChar()) { // 小数点后面必须是数字 1.foo 不是浮点数
+		tokenType = token.FLOAT
+		l.readChar()
+		l.readDigits()
+	}
+	if l.ch == 'e' || l.ch == 'E' { // e后面没有数字的 1e 1e+ 也是浮点数 交给解析器报错
+		tokenType = token.FLOAT
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		l.readDigits()
+	}
+	return tokenType, l.input[position:l.position] // position所在的字符不是数字了
+}
+
+func (l *Lexer) readDigits() {
+	for isDigit(l.ch) || l.ch == '_' { // 只要是数字或者分隔符一直读取 start:end
 		l.readChar()
 	}
-	return l.input[position:l.position] // position所在的字符不是letter了
 }
 
 // 偷看当前字符的下一个字符是什么 并返回
-func (l *Lexer) peekChar() byte {
+func (l *Lexer) peekChar() rune {
 	if l.readPosition >= len(l.input) {
 		return 0
-	} else {
-		return l.input[l.readPosition]
 	}
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition:])
+	return ch
+}
+
+// 词法分析过程中遇到的错误
+func (l *Lexer) Errors() []Error {
+	return l.errors
 }
 
-// TODO 支持 \n 转义字符 支持没有结束 " 报错 ？
-// 拿到一个字符串 "add" => add
-func (l *Lexer) readString() string {
+// 已经跳过的注释 词法单元的类型是 COMMENT 字面量是包含 // 或 /* */ 的原文
+func (l *Lexer) Comments() []token.Token {
+	return l.comments
+}
+
+// 记录错误 区间从start到当前字符之后
+func (l *Lexer) addError(kind ErrorKind, start token.Position, format string, a ...any) {
+	end := l.pos()
+	if !l.atEOF() {
+		end.Offset = l.readPosition
+		end.Column++
+	}
+	l.errors = append(l.errors, Error{Kind: kind, Start: start, End: end, Message: fmt.Sprintf(format, a...)})
+}
+
+// 是否已经读取到输入的末尾 输入中的 \0 字符不是末尾
+func (l *Lexer) atEOF() bool {
+	return l.position >= len(l.input)
+}
+
+// 拿到一个字符串 "add" => add 同时解码转义字符
+func (l *Lexer) readString(start token.Position) string {
+	var out strings.Builder
+	for {
+		l.readChar()
+		switch {
+		case l.atEOF():
+			l.errors = append(l.errors, Error{
+				Kind: UnterminatedString, Start: start, End: l.pos(), Message: "unterminated string literal",
+			})
+			return out.String()
+		case l.ch == '"':
+			return out.String()
+		case l.ch == '\\':
+			l.readEscape(&out)
+		default: // 保留原始的字节 不合法的utf-8也原样保留
+			out.WriteString(l.input[l.position:l.readPosition])
+		}
+	}
+}
+
+// 单个字符的转义
+var escapes = map[rune]rune{
+	'n':  '\n',
+	't':  '\t',
+	'r':  '\r',
+	'0':  0,
+	'\\': '\\',
+	'"':  '"',
+	'\'': '\'',
+}
+
+// 解码一个转义字符 当前字符是 \ 结束时当前字符是转义的最后一个字符
+// 不合法的转义记录错误后跳过
+func (l *Lexer) readEscape(out *strings.Builder) {
+	start := l.pos()
+	next := l.peekChar()
+	if r, ok := escapes[next]; ok {
+		l.readChar()
+		out.WriteRune(r)
+		return
+	}
+	if next == 'u' {
+		l.readChar()
+		l.readUnicodeEscape(start, out)
+		return
+	}
+	if l.readPosition >= len(l.input) { // 反斜杠在末尾 由字符串没有结束的错误报告
+		return
+	}
+	l.readChar()
+	l.addError(InvalidEscape, start, "unknown escape sequence \\%c", l.ch)
+}
+
+// \u{1F600} 花括号中是1到6位十六进制数 当前字符是 u
+func (l *Lexer) readUnicodeEscape(start token.Position, out *strings.Builder) {
+	if l.peekChar() != '{' {
+		l.addError(InvalidEscape, start, "invalid unicode escape, expected \\u{hex digits}")
+		return
+	}
+	l.readChar()
+	var value rune
+	digits := 0
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+		digits++
+		if digits <= 6 {
+			value = value*16 + hexValue(l.ch)
+		}
+	}
+	if l.peekChar() != '}' { // 没有结束的 } 后面的字符仍然属于字符串
+		l.addError(InvalidEscape, start, "invalid unicode escape, expected \\u{hex digits}")
+		return
+	}
+	l.readChar()
+	if digits == 0 || digits > 6 {
+		l.addError(InvalidEscape, start, "invalid unicode escape, expected \\u{hex digits}")
+		return
+	}
+	if !utf8.ValidRune(value) { // 超出范围或者是代理对
+		l.addError(InvalidEscape, start, "invalid unicode code point U+%X in escape", value)
+		return
+	}
+	out.WriteRune(value)
+}
+
+// 原始字符串 `raw` 内容原样保留 不处理转义
+func (l *Lexer) readRawString(start token.Position) string {
 	position := l.position + 1
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+		if l.atEOF() {
+			l.errors = append(l.errors, Error{
+				Kind: UnterminatedString, Start: start, End: l.pos(), Message: "unterminated raw string literal",
+			})
+			return l.input[position:]
+		}
+		if l.ch == '`' {
+			return l.input[position:l.position]
 		}
 	}
-	return l.input[position:l.position]
 }
 
 // ============= 函数 ================
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{Type: tokenType, Literal: string(ch)}
 }
 
-func isLetter(ch byte) bool {
-	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_'
+// 标识符的开头 Unicode字母或者下划线 支持中文等非英文的名称
+func isLetter(ch rune) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z' || ch == '_' ||
+		ch >= utf8.RuneSelf && unicode.IsLetter(ch)
+}
+
+// 数字字面量只支持ASCII数字
+func isDigit(ch rune) bool {
+	return ch >= '0' && ch <= '9'
 }
 
-func isDigit(ch byte) bool {
-	return ch >= '0' && ch <= '9' // TODO 如何支持浮点型？ 二进制八进制十六进制
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || ch >= 'a' && ch <= 'f' || ch >= 'A' && ch <= 'F'
+}
+
+func hexValue(ch rune) rune {
+	switch {
+	case ch >= 'a':
+		return ch - 'a' + 10
+	case ch >= 'A':
+		return ch - 'A' + 10
+	default:
+		return ch - '0'
+	}
+}
+
+// 0x 0o 0b 前缀的第二个字符
+func isBasePrefix(ch rune) bool {
+	switch ch {
+	case 'x', 'X', 'o', 'O', 'b', 'B':
+		return true
+	}
+	return false
 }
@@ -0,0 +1,117 @@
+package lexer
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+func TestComments(t *testing.T) {
+	input := `// 行注释
+let a = 10 / 2; // 除法不是注释
+/* 块注释
+   可以跨行 */
+let b = /* 表达式中间 */ a;
+/* 外层 /* 内层 */ 仍然是注释 */
+a//b
+`
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{token.LET, "let"},
+		{token.IDENT, "a"},
+		{token.ASSIGN, "="},
+		{token.INT, "10"},
+		{token.SLASH, "/"},
+		{token.INT, "2"},
+		{token.SEMICOLON, ";"},
+		{token.LET, "let"},
+		{token.IDENT, "b"},
+		{token.ASSIGN, "="},
+		{token.IDENT, "a"},
+		{token.SEMICOLON, ";"},
+		{token.IDENT, "a"},
+		{token.EOF, ""},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - expected %s %q, got %s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+	if len(l.Errors()) != 0 {
+		t.Errorf("unexpected errors %+v", l.Errors())
+	}
+
+	expectedComments := []struct {
+		literal string
+		line    int
+		column  int
+	}{
+		{"// 行注释", 1, 1},
+		{"// 除法不是注释", 2, 17},
+		{"/* 块注释\n   可以跨行 */", 3, 1},
+		{"/* 表达式中间 */", 5, 9},
+		{"/* 外层 /* 内层 */ 仍然是注释 */", 6, 1},
+		{"//b", 7, 2},
+	}
+	comments := l.Comments()
+	if len(comments) != len(expectedComments) {
+		t.Fatalf("expected %d comments, got %+v", len(expectedComments), comments)
+	}
+	for i, expected := range expectedComments {
+		c := comments[i]
+		if c.Type != token.COMMENT || c.Literal != expected.literal ||
+			c.Pos.Line != expected.line || c.Pos.Column != expected.column {
+			t.Errorf("comments[%d] - expected %q at %d:%d, got %s %q at %d:%d", i,
+				expected.literal, expected.line, expected.column, c.Type, c.Literal, c.Pos.Line, c.Pos.Column)
+		}
+	}
+}
+
+func TestCommentBoundaries(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.TokenType
+	}{
+		{"// 只有注释", []token.TokenType{token.EOF}},
+		{"/**/1", []token.TokenType{token.INT, token.EOF}},
+		{"/*/ 不是结束 */1", []token.TokenType{token.INT, token.EOF}},
+		{"1 /2", []token.TokenType{token.INT, token.SLASH, token.INT, token.EOF}},
+		{"1 */ 2", []token.TokenType{token.INT, token.ASTERISK, token.SLASH, token.INT, token.EOF}},
+		{`"// 字符串中不是注释"`, []token.TokenType{token.STRING, token.EOF}},
+		{"// a\r\nb", []token.TokenType{token.IDENT, token.EOF}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, expected := range tt.expected {
+			if tok := l.NextToken(); tok.Type != expected {
+				t.Errorf("%q: tokens[%d] - expected %s, got %s %q", tt.input, i, expected, tok.Type, tok.Literal)
+				break
+			}
+		}
+	}
+}
+
+func TestUnterminatedComment(t *testing.T) {
+	l := New("1 /* a /* b */ c")
+	if tok := l.NextToken(); tok.Type != token.INT {
+		t.Fatalf("expected INT, got %s", tok.Type)
+	}
+	if tok := l.NextToken(); tok.Type != token.EOF {
+		t.Fatalf("expected EOF, got %s %q", tok.Type, tok.Literal)
+	}
+	errs := l.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %+v", errs)
+	}
+	e := errs[0]
+	// 只标出开头的 /*
+	if e.Kind != UnterminatedComment || e.Message != "unterminated block comment" ||
+		e.Start.Column != 3 || e.End.Column != 5 || e.End.Offset != 4 {
+		t.Errorf("wrong error %+v", e)
+	}
+}
@@ -0,0 +1,66 @@
+package lexer
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+func TestNumberTokens(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedType    token.TokenType
+		expectedLiteral string
+	}{
+		{"5", token.INT, "5"},
+		{"3.14", token.FLOAT, "3.14"},
+		{"0.5", token.FLOAT, "0.5"},
+		{"1e9", token.FLOAT, "1e9"},
+		{"1e-9", token.FLOAT, "1e-9"},
+		{"2.5E+3", token.FLOAT, "2.5E+3"},
+		{"0x1F", token.INT, "0x1F"},
+		{"0o17", token.INT, "0o17"},
+		{"0b1010", token.INT, "0b1010"},
+		{"1_000_000", token.INT, "1_000_000"},
+		{"0b102", token.INT, "0b102"}, // 不合法的数字交给解析器报错
+		{"0xZZ", token.INT, "0xZZ"},
+		{"1_000.000_1", token.FLOAT, "1_000.000_1"},
+	}
+
+	for i, tt := range tests {
+		tok := New(tt.input).NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Errorf("tests[%d] - expected %s %q, got %s %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+	}
+}
+
+// 小数点后面不是数字时 不属于数字 e总是属于数字
+func TestNumberBoundaries(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"1.foo", []token.Token{{Type: token.INT, Literal: "1"}, {Type: token.ILLEGAL, Literal: "."}, {Type: token.IDENT, Literal: "foo"}}},
+		// e后面没有数字时仍然是浮点数 由解析器报告指数不合法
+		{"2e", []token.Token{{Type: token.FLOAT, Literal: "2e"}}},
+		{"1e+", []token.Token{{Type: token.FLOAT, Literal: "1e+"}}},
+		{"3e+x", []token.Token{{Type: token.FLOAT, Literal: "3e+"}, {Type: token.IDENT, Literal: "x"}}},
+		{"4Ex", []token.Token{{Type: token.FLOAT, Literal: "4E"}, {Type: token.IDENT, Literal: "x"}}},
+		{"1.5-2", []token.Token{{Type: token.FLOAT, Literal: "1.5"}, {Type: token.MINUS, Literal: "-"}, {Type: token.INT, Literal: "2"}}},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		for i, expected := range tt.expected {
+			tok := l.NextToken()
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Errorf("%q tokens[%d] - expected %s %q, got %s %q",
+					tt.input, i, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("%q - expected EOF, got %s %q", tt.input, tok.Type, tok.Literal)
+		}
+	}
+}
@@ -0,0 +1,146 @@
+package lexer
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+func TestBitwiseOperatorTokens(t *testing.T) {
+	input := "a & b | c ^ ~d << 2 >> 1 < >"
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.BIT_AND, Literal: "&"},
+		{Type: token.IDENT, Literal: "b"},
+		{Type: token.BIT_OR, Literal: "|"},
+		{Type: token.IDENT, Literal: "c"},
+		{Type: token.BIT_XOR, Literal: "^"},
+		{Type: token.BIT_NOT, Literal: "~"},
+		{Type: token.IDENT, Literal: "d"},
+		{Type: token.SHL, Literal: "<<"},
+		{Type: token.INT, Literal: "2"},
+		{Type: token.SHR, Literal: ">>"},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.LT, Literal: "<"},
+		{Type: token.GT, Literal: ">"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.Type || tok.Literal != tt.Literal {
+			t.Fatalf("tokens[%d] - expected %s %q, got %s %q", i, tt.Type, tt.Literal, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestLogicalAndComparisonTokens(t *testing.T) {
+	input := "a && b || c <= d >= e & f | g << h"
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.AND, Literal: "&&"},
+		{Type: token.IDENT, Literal: "b"},
+		{Type: token.OR, Literal: "||"},
+		{Type: token.IDENT, Literal: "c"},
+		{Type: token.LT_EQ, Literal: "<="},
+		{Type: token.IDENT, Literal: "d"},
+		{Type: token.GT_EQ, Literal: ">="},
+		{Type: token.IDENT, Literal: "e"},
+		{Type: token.BIT_AND, Literal: "&"},
+		{Type: token.IDENT, Literal: "f"},
+		{Type: token.BIT_OR, Literal: "|"},
+		{Type: token.IDENT, Literal: "g"},
+		{Type: token.SHL, Literal: "<<"},
+		{Type: token.IDENT, Literal: "h"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.Type || tok.Literal != tt.Literal {
+			t.Fatalf("tokens[%d] - expected %s %q, got %s %q", i, tt.Type, tt.Literal, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestAssignmentTokens(t *testing.T) {
+	input := "x = 1; x += 2; x -= 3; x *= 4; x /= 5; x == y; x + = y"
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.INT, Literal: "1"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.PLUS_ASSIGN, Literal: "+="},
+		{Type: token.INT, Literal: "2"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.MINUS_ASSIGN, Literal: "-="},
+		{Type: token.INT, Literal: "3"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.ASTERISK_ASSIGN, Literal: "*="},
+		{Type: token.INT, Literal: "4"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.SLASH_ASSIGN, Literal: "/="},
+		{Type: token.INT, Literal: "5"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.EQ, Literal: "=="},
+		{Type: token.IDENT, Literal: "y"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.PLUS, Literal: "+"}, // 中间有空格不是复合赋值
+		{Type: token.ASSIGN, Literal: "="},
+		{Type: token.IDENT, Literal: "y"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.Type || tok.Literal != tt.Literal {
+			t.Fatalf("tokens[%d] - expected %s %q, got %s %q", i, tt.Type, tt.Literal, tok.Type, tok.Literal)
+		}
+	}
+}
+
+func TestModuloAndPowerTokens(t *testing.T) {
+	input := "a % b ** c; x %= 2; x **= 3; a * * b; a***b"
+	expected := []token.Token{
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.PERCENT, Literal: "%"},
+		{Type: token.IDENT, Literal: "b"},
+		{Type: token.POWER, Literal: "**"},
+		{Type: token.IDENT, Literal: "c"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.PERCENT_ASSIGN, Literal: "%="},
+		{Type: token.INT, Literal: "2"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "x"},
+		{Type: token.POWER_ASSIGN, Literal: "**="},
+		{Type: token.INT, Literal: "3"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.ASTERISK, Literal: "*"}, // 中间有空格是两个乘号
+		{Type: token.ASTERISK, Literal: "*"},
+		{Type: token.IDENT, Literal: "b"},
+		{Type: token.SEMICOLON, Literal: ";"},
+		{Type: token.IDENT, Literal: "a"},
+		{Type: token.POWER, Literal: "**"}, // 最长匹配
+		{Type: token.ASTERISK, Literal: "*"},
+		{Type: token.IDENT, Literal: "b"},
+		{Type: token.EOF, Literal: ""},
+	}
+
+	l := New(input)
+	for i, tt := range expected {
+		tok := l.NextToken()
+		if tok.Type != tt.Type || tok.Literal != tt.Literal {
+			t.Fatalf("tokens[%d] - expected %s %q, got %s %q", i, tt.Type, tt.Literal, tok.Type, tok.Literal)
+		}
+	}
+}
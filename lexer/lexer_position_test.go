@@ -0,0 +1,67 @@
+package lexer
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+// 词法单元的位置 行号列号从1开始 偏移量从0开始
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 5;\n  x == \"a\";\n"
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+	}{
+		{token.LET, token.Position{Filename: "main.mk", Offset: 0, Line: 1, Column: 1}},
+		{token.IDENT, token.Position{Filename: "main.mk", Offset: 4, Line: 1, Column: 5}},
+		{token.ASSIGN, token.Position{Filename: "main.mk", Offset: 6, Line: 1, Column: 7}},
+		{token.INT, token.Position{Filename: "main.mk", Offset: 8, Line: 1, Column: 9}},
+		{token.SEMICOLON, token.Position{Filename: "main.mk", Offset: 9, Line: 1, Column: 10}},
+		{token.IDENT, token.Position{Filename: "main.mk", Offset: 13, Line: 2, Column: 3}},
+		{token.EQ, token.Position{Filename: "main.mk", Offset: 15, Line: 2, Column: 5}},
+		{token.STRING, token.Position{Filename: "main.mk", Offset: 18, Line: 2, Column: 8}},
+		{token.SEMICOLON, token.Position{Filename: "main.mk", Offset: 21, Line: 2, Column: 11}},
+		{token.EOF, token.Position{Filename: "main.mk", Offset: 23, Line: 3, Column: 1}},
+	}
+	l := NewWithFilename("main.mk", input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokenType wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - position wrong. expected=%+v, got=%+v", i, tt.expectedPos, tok.Pos)
+		}
+	}
+	if got := tests[5].expectedPos.String(); got != "main.mk:2:3" {
+		t.Errorf("position string wrong. got=%q", got)
+	}
+}
+
+// 词法单元结束的位置 字符串按源码计算 包括引号 转义和跨行的原始字符串
+func TestTokenEndPositions(t *testing.T) {
+	input := "let \"\\u{1F600}\\u{41}\" = `a\nbc` 中;"
+	tests := []struct {
+		expectedType token.TokenType
+		expectedEnd  token.Position
+	}{
+		{token.LET, token.Position{Offset: 3, Line: 1, Column: 4}},
+		{token.STRING, token.Position{Offset: 21, Line: 1, Column: 22}},
+		{token.ASSIGN, token.Position{Offset: 23, Line: 1, Column: 24}},
+		{token.STRING, token.Position{Offset: 30, Line: 2, Column: 4}},
+		{token.IDENT, token.Position{Offset: 34, Line: 2, Column: 6}},
+		{token.SEMICOLON, token.Position{Offset: 35, Line: 2, Column: 7}},
+	}
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokenType wrong. expected=%q, got=%q",
+				i, tt.expectedType, tok.Type)
+		}
+		if tok.End != tt.expectedEnd {
+			t.Fatalf("tests[%d] - end position wrong. expected=%+v, got=%+v", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package lexer
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+func TestStringEscapes(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"plain"`, "plain"},
+		{`"a\nb"`, "a\nb"},
+		{`"tab\there"`, "tab\there"},
+		{`"cr\r"`, "cr\r"},
+		{`"say \"hi\""`, `say "hi"`},
+		{`"back\\slash"`, `back\slash`},
+		{`"it\'s"`, "it's"},
+		{`"nul\0"`, "nul\x00"},
+		{`"\u{41}\u{4e2d}"`, "A中"},
+		{`"\u{1F600}"`, "😀"},
+		{`"中文"`, "中文"},
+		{"\"two\nlines\"", "two\nlines"}, // 双引号字符串也可以直接包含换行
+		{"`raw \\n \"string\"`", `raw \n "string"`},
+		{"`multi\nline`", "multi\nline"},
+		{"``", ""},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.STRING {
+			t.Errorf("%s: expected STRING, got %s", tt.input, tok.Type)
+			continue
+		}
+		if tok.Literal != tt.expected {
+			t.Errorf("%s: expected %q, got %q", tt.input, tt.expected, tok.Literal)
+		}
+		if len(l.Errors()) != 0 {
+			t.Errorf("%s: unexpected errors %+v", tt.input, l.Errors())
+		}
+		if next := l.NextToken(); next.Type != token.EOF {
+			t.Errorf("%s: expected EOF after string, got %s %q", tt.input, next.Type, next.Literal)
+		}
+	}
+}
+
+func TestStringErrors(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedLiteral string
+		expectedKind    ErrorKind
+		expectedStart   int // 错误开始的列
+		expectedEnd     int // 错误结束的列 不包含
+		expectedMessage string
+	}{
+		{`"abc`, "abc", UnterminatedString, 1, 5, "unterminated string literal"},
+		{"`abc", "abc", UnterminatedString, 1, 5, "unterminated raw string literal"},
+		{`"a\qb"`, "ab", InvalidEscape, 3, 5, `unknown escape sequence \q`},
+		{`"\u41"`, "41", InvalidEscape, 2, 4, `invalid unicode escape, expected \u{hex digits}`},
+		{`"\u{}"`, "", InvalidEscape, 2, 6, `invalid unicode escape, expected \u{hex digits}`},
+		{`"\u{1234567}"`, "", InvalidEscape, 2, 13, `invalid unicode escape, expected \u{hex digits}`},
+		{`"\u{110000}"`, "", InvalidEscape, 2, 12, `invalid unicode code point U+110000 in escape`},
+		{`"\u{41x"`, "x", InvalidEscape, 2, 7, `invalid unicode escape, expected \u{hex digits}`},
+		{`"\u{D800}"`, "", InvalidEscape, 2, 10, `invalid unicode code point U+D800 in escape`},
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.STRING || tok.Literal != tt.expectedLiteral {
+			t.Errorf("%s: expected STRING %q, got %s %q", tt.input, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		errs := l.Errors()
+		if len(errs) != 1 {
+			t.Errorf("%s: expected 1 error, got %+v", tt.input, errs)
+			continue
+		}
+		e := errs[0]
+		if e.Kind != tt.expectedKind || e.Message != tt.expectedMessage {
+			t.Errorf("%s: expected %d %q, got %d %q", tt.input, tt.expectedKind, tt.expectedMessage, e.Kind, e.Message)
+		}
+		if e.Start.Column != tt.expectedStart || e.End.Column != tt.expectedEnd {
+			t.Errorf("%s: expected columns [%d, %d), got [%d, %d)",
+				tt.input, tt.expectedStart, tt.expectedEnd, e.Start.Column, e.End.Column)
+		}
+	}
+}
+
+// 跨行的原始字符串之后 位置仍然正确
+func TestRawStringPositions(t *testing.T) {
+	l := New("`a\nbc` x")
+	l.NextToken()
+	tok := l.NextToken()
+	if tok.Type != token.IDENT || tok.Pos.Line != 2 || tok.Pos.Column != 5 {
+		t.Errorf("wrong token after raw string. got=%s at %s", tok.Type, tok.Pos)
+	}
+}
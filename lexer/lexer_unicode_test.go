@@ -0,0 +1,77 @@
+package lexer
+
+import (
+	"monkey/token"
+	"testing"
+)
+
+func TestUnicodeIdentifiers(t *testing.T) {
+	input := "let 名字 = \"猴子\";\nlet café_2 = 名字;\nx1 + _y"
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedLine    int
+		expectedColumn  int // 按字符计数
+	}{
+		{token.LET, "let", 1, 1},
+		{token.IDENT, "名字", 1, 5},
+		{token.ASSIGN, "=", 1, 8},
+		{token.STRING, "猴子", 1, 10},
+		{token.SEMICOLON, ";", 1, 14},
+		{token.LET, "let", 2, 1},
+		{token.IDENT, "café_2", 2, 5},
+		{token.ASSIGN, "=", 2, 12},
+		{token.IDENT, "名字", 2, 14},
+		{token.SEMICOLON, ";", 2, 16},
+		{token.IDENT, "x1", 3, 1},
+		{token.PLUS, "+", 3, 4},
+		{token.IDENT, "_y", 3, 6},
+		{token.EOF, "", 3, 8},
+	}
+
+	l := New(input)
+	for i, tt := range tests {
+		tok := l.NextToken()
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - expected %s %q, got %s %q", i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+		if tok.Pos.Line != tt.expectedLine || tok.Pos.Column != tt.expectedColumn {
+			t.Errorf("tests[%d] %q - expected %d:%d, got %d:%d",
+				i, tok.Literal, tt.expectedLine, tt.expectedColumn, tok.Pos.Line, tok.Pos.Column)
+		}
+	}
+}
+
+// 偏移量仍然按字节计算 可以直接用来截取源码
+func TestUnicodeOffsets(t *testing.T) {
+	input := "名字 + 1"
+	l := New(input)
+	l.NextToken()
+	plus := l.NextToken()
+	if plus.Pos.Offset != 7 || input[plus.Pos.Offset:plus.Pos.Offset+1] != "+" {
+		t.Errorf("wrong offset for +. got=%d", plus.Pos.Offset)
+	}
+}
+
+func TestIllegalCharacters(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"€", "€"},       // 不是字母的符号
+		{"\xff", "\xff"}, // 不合法的utf-8保留原始字节
+		{"\x00", "\x00"}, // 源码中的 \0 不是输入的结束
+		{"　", "　"},       // 全角空格不是空白字符
+	}
+
+	for _, tt := range tests {
+		l := New(tt.input)
+		tok := l.NextToken()
+		if tok.Type != token.ILLEGAL || tok.Literal != tt.expected {
+			t.Errorf("%q: expected ILLEGAL %q, got %s %q", tt.input, tt.expected, tok.Type, tok.Literal)
+		}
+		if tok := l.NextToken(); tok.Type != token.EOF {
+			t.Errorf("%q: expected EOF, got %s %q", tt.input, tok.Type, tok.Literal)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"monkey/repl"
+	"monkey/vm"
+)
+
+// 执行一段源码 返回程序的结果和退出码 出错时把诊断信息写到stderr
+// 程序最后一条语句没有值时结果为nil
+func execute(filename, source string, args []string, stderr io.Writer, opts repl.Options) (object.Object, int) {
+	l := lexer.NewWithFilename(filename, source)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		for _, d := range p.Diagnostics() {
+			fmt.Fprintf(stderr, "%s: %s[%s]: %s\n", d.Span.Start, d.Severity, d.Code, d.Message)
+			if snippet := d.Snippet(source); snippet != "" {
+				fmt.Fprintln(stderr, snippet)
+			}
+		}
+		return nil, exitError
+	}
+	macroEnv := object.NewEnvironment()
+	evaluator.DefineMacros(program, macroEnv)
+	expanded := evaluator.ExpandMacros(program, macroEnv)
+
+	var result object.Object
+	if opts.Engine == repl.EngineVM {
+		var err error
+		var halted bool
+		if result, halted, err = runVM(expanded, args, opts.CheckedArithmetic); err != nil {
+			fmt.Fprintf(stderr, "%s\n", err)
+			return nil, exitError
+		}
+		if !compiler.EndsWithValue(program) && !halted {
+			result = nil // 虚拟机栈上是之前的值
+		}
+	} else {
+		env := object.NewEnvironment()
+		env.Set("args", argsArray(args))
+		env.CallStack().CheckedArithmetic = opts.CheckedArithmetic
+		result = evaluator.Eval(expanded, env)
+	}
+	if err, ok := result.(*object.Error); ok {
+		io.WriteString(stderr, err.Traceback()) // 发生在函数调用中的错误才有调用链
+		fmt.Fprintf(stderr, "%s: runtime error: %s\n", err.Pos, err.Message)
+		return nil, exitError
+	}
+	return result, exitOK
+}
+
+func runVM(program ast.Node, args []string, checked bool) (object.Object, bool, error) {
+	symbolTable := compiler.NewGlobalSymbolTable()
+	argsSymbol := symbolTable.Define("args")
+	globals := make([]object.Object, vm.GlobalsSize)
+	globals[argsSymbol.Index] = argsArray(args)
+	comp := compiler.NewWithState(symbolTable, []object.Object{})
+	if err := comp.Compile(program); err != nil {
+		return nil, false, err
+	}
+	machine := vm.NewWithGlobalsStore(comp.Bytecode(), globals)
+	machine.CheckedArithmetic = checked
+	if err := machine.Run(); err != nil {
+		return nil, false, err
+	}
+	return machine.LastPoppedStackElem(), machine.Halted(), nil
+}
+
+// 脚本参数 字符串数组
+func argsArray(args []string) *object.Array {
+	elements := make([]object.Object, len(args))
+	for i, arg := range args {
+		elements[i] = &object.String{Value: arg}
+	}
+	return &object.Array{Elements: elements}
+}
@@ -1,17 +1,124 @@
+// 命令行入口
+//
+//	monkey [repl]                    交互式执行
+//	monkey run <file|-> [args...]    执行脚本文件 - 表示从标准输入读取
+//	monkey eval -e '<expr>' [args...] 执行一段代码并打印结果
+//
+// 脚本参数在程序中通过 args 数组获取
 package main
 
 import (
+	"flag"
 	"fmt"
+	"io"
 	"monkey/repl"
 	"os"
 	"os/user"
 )
 
+const usage = `usage: monkey [-engine=eval|vm] [-checked] <command> [arguments]
+
+commands:
+  repl                        start the interactive REPL (default)
+  run <file|-> [args...]      run a script file, - reads the script from stdin
+  eval -e '<expr>' [args...]  evaluate an expression and print the result
+
+options:
+  -engine=eval|vm             evaluate with the tree-walking evaluator (default) or the bytecode VM
+  -checked                    report integer overflow as a runtime error
+`
+
+// 退出码
+const (
+	exitOK    = 0
+	exitError = 1 // 语法错误 编译错误 未捕获的运行时错误
+	exitUsage = 2 // 命令行参数错误
+)
+
 func main() {
+	os.Exit(runMain(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func runMain(arguments []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("monkey", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() { fmt.Fprint(stderr, usage) }
+	engine := flags.String("engine", repl.EngineEval, "use 'eval' or 'vm'")
+	checked := flags.Bool("checked", false, "report integer overflow as a runtime error")
+	if err := flags.Parse(arguments); err != nil {
+		return exitUsage
+	}
+	if *engine != repl.EngineEval && *engine != repl.EngineVM {
+		fmt.Fprintf(stderr, "monkey: unknown engine %q\n", *engine)
+		return exitUsage
+	}
+	command, rest := "repl", []string{}
+	if flags.NArg() > 0 {
+		command, rest = flags.Arg(0), flags.Args()[1:]
+	}
+	opts := repl.Options{Engine: *engine, CheckedArithmetic: *checked}
+	switch command {
+	case "repl":
+		return startRepl(stdin, stdout, opts)
+	case "run":
+		return runFile(rest, stdin, stderr, opts)
+	case "eval":
+		return evalExpression(rest, stdout, stderr, opts)
+	default:
+		fmt.Fprintf(stderr, "monkey: unknown command %q\n", command)
+		fmt.Fprint(stderr, usage)
+		return exitUsage
+	}
+}
+
+func startRepl(stdin io.Reader, stdout io.Writer, opts repl.Options) int {
 	user, err := user.Current() // 当前的用户
+	if err == nil {
+		fmt.Fprintf(stdout, "Hello %s! This is the Monkey programming language!\n", user.Username) // 获取用户名
+	}
+	repl.StartWithOptions(stdin, stdout, opts)
+	return exitOK
+}
+
+// monkey run <file|-> [args...]
+func runFile(arguments []string, stdin io.Reader, stderr io.Writer, opts repl.Options) int {
+	if len(arguments) == 0 {
+		fmt.Fprint(stderr, "monkey run: missing script file\n")
+		return exitUsage
+	}
+	path, args := arguments[0], arguments[1:]
+	var source []byte
+	var err error
+	filename := path
+	if path == "-" {
+		filename = "<stdin>"
+		source, err = io.ReadAll(stdin)
+	} else {
+		source, err = os.ReadFile(path)
+	}
 	if err != nil {
-		return
+		fmt.Fprintf(stderr, "monkey run: %s\n", err)
+		return exitError
+	}
+	_, code := execute(filename, string(source), args, stderr, opts)
+	return code
+}
+
+// monkey eval -e '<expr>' [args...]
+func evalExpression(arguments []string, stdout, stderr io.Writer, opts repl.Options) int {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	expr := flags.String("e", "", "the Monkey source to evaluate")
+	if err := flags.Parse(arguments); err != nil {
+		return exitUsage
+	}
+	if *expr == "" {
+		fmt.Fprint(stderr, "monkey eval: missing -e '<expr>'\n")
+		return exitUsage
+	}
+	result, code := execute("<eval>", *expr, flags.Args(), stderr, opts)
+	if result != nil && code == exitOK {
+		fmt.Fprintln(stdout, result.Inspect())
 	}
-	fmt.Printf("Hello %s! This is the Monkey programming language!\n", user.Username) // 获取用户名
-	repl.Start(os.Stdin, os.Stdout)
+	return code
 }
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRuntimeErrorTraceback(t *testing.T) {
+	script := filepath.Join(t.TempDir(), "trace.mk")
+	source := "let check = fn(x) {\n  let n = len(x); n\n};\nlet main = fn() { let r = check(1); r };\nmain()"
+	if err := os.WriteFile(script, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	expected := "Traceback (most recent call last):\n" +
+		"  File \"" + script + "\", line 5, column 5, in <program>\n" +
+		"  File \"" + script + "\", line 4, column 32, in main(0 args)\n" +
+		"  File \"" + script + "\", line 2, column 14, in check(1 arg)\n" +
+		script + ":2:14: runtime error: argument to `len` not supported, got INTEGER\n"
+	for _, engine := range []string{"-engine=eval", "-engine=vm"} {
+		var stdout, stderr bytes.Buffer
+		if code := runMain([]string{engine, "run", script}, strings.NewReader(""), &stdout, &stderr); code != exitError {
+			t.Fatalf("%s: wrong exit code. want=%d, got=%d", engine, exitError, code)
+		}
+		if stderr.String() != expected {
+			t.Errorf("%s: wrong stderr. want=%q, got=%q", engine, expected, stderr.String())
+		}
+	}
+}
+
+func TestRunMain(t *testing.T) {
+	dir := t.TempDir()
+	script := filepath.Join(dir, "script.mk")
+	source := "let n = len(args);\nif (n > 1) { 1 + true }"
+	if err := os.WriteFile(script, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		args           []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+	}{
+		{[]string{"eval", "-e", "1 + 2"}, "", exitOK, "3\n", ""},
+		{[]string{"-engine=vm", "eval", "-e", "1 + 2"}, "", exitOK, "3\n", ""},
+		{[]string{"eval", "-e", "args", "a", "b"}, "", exitOK, "[a, b]\n", ""},
+		{[]string{"-engine=vm", "eval", "-e", "args", "a", "b"}, "", exitOK, "[a, b]\n", ""},
+		{[]string{"eval", "-e", "let x = 1;"}, "", exitOK, "", ""},
+		{[]string{"eval", "-e", "let x = ;"}, "", exitError, "", "<eval>:1:9: error[E0002]: no prefix parse function for ; found."},
+		{[]string{"run", script, "a"}, "", exitOK, "", ""},
+		{[]string{"run", script, "a", "b"}, "", exitError, "", "script.mk:2:16: runtime error: type mismatch: INTEGER + BOOLEAN"},
+		{[]string{"-engine=vm", "run", script, "a", "b"}, "", exitError, "", "script.mk:2:16: runtime error: type mismatch: INTEGER + BOOLEAN"},
+		{[]string{"run", "-"}, "-true", exitError, "", "<stdin>:1:1: runtime error: unknown operator: -BOOLEAN"},
+		{[]string{"-engine=vm", "eval", "-e", "foo"}, "", exitError, "", "<eval>:1:1: runtime error: identifier not found: foo"},
+		// 最后一条语句不产生值时 运行时错误也要报告
+		{[]string{"eval", "-e", "for (x in 1) { }"}, "", exitError, "", "<eval>:1:1: runtime error: cannot iterate over INTEGER"},
+		{[]string{"-engine=vm", "eval", "-e", "for (x in 1) { }"}, "", exitError, "", "<eval>:1:1: runtime error: cannot iterate over INTEGER"},
+		{[]string{"-engine=vm", "eval", "-e", "let x = -true;"}, "", exitError, "", "<eval>:1:9: runtime error: unknown operator: -BOOLEAN"},
+		{[]string{"-engine=vm", "eval", "-e", "let i = 0; while (i < 3) { i += 1 }"}, "", exitOK, "", ""},
+		{[]string{"eval", "-e", `try { throw "x" } catch (e) { e["message"] }`}, "", exitOK, "x\n", ""},
+		{[]string{"-engine=vm", "eval", "-e", `try { throw "x" } catch (e) { e["message"] }`}, "", exitOK, "x\n", ""},
+		{[]string{"eval", "-e", `throw "boom";`}, "", exitError, "", "<eval>:1:1: runtime error: boom"},
+		{[]string{"eval", "-e", "10 / 0"}, "", exitError, "", "<eval>:1:4: runtime error: division by zero"},
+		{[]string{"eval", "-e", "9223372036854775807 + 1"}, "", exitOK, "9223372036854775808\n", ""},
+		{[]string{"-checked", "eval", "-e", "9223372036854775807 + 1"}, "", exitError, "", "<eval>:1:21: runtime error: integer overflow: 9223372036854775807 + 1"},
+		{[]string{"-checked", "-engine=vm", "eval", "-e", "9223372036854775807 + 1"}, "", exitError, "", "<eval>:1:21: runtime error: integer overflow: 9223372036854775807 + 1"},
+		{[]string{"eval", "-e", "9223372036854775807 + 1"}, "", exitOK, "9223372036854775808\n", ""},
+		{[]string{"-engine=vm", "eval", "-e", `throw "boom";`}, "", exitError, "", "<eval>:1:1: runtime error: boom"},
+		{[]string{"run"}, "", exitUsage, "", "missing script file"},
+		{[]string{"eval"}, "", exitUsage, "", "missing -e"},
+		{[]string{"unknown"}, "", exitUsage, "", `unknown command "unknown"`},
+		{[]string{"-engine=jit", "repl"}, "", exitUsage, "", `unknown engine "jit"`},
+		{[]string{"-h"}, "", exitUsage, "", "\n  -engine=eval|vm "},
+		{[]string{"-h"}, "", exitUsage, "", "\n  -checked "},
+	}
+
+	for _, tt := range tests {
+		var stdout, stderr bytes.Buffer
+		code := runMain(tt.args, strings.NewReader(tt.stdin), &stdout, &stderr)
+		if code != tt.expectedCode {
+			t.Errorf("%v: wrong exit code. want=%d, got=%d (stderr=%q)", tt.args, tt.expectedCode, code, stderr.String())
+		}
+		if stdout.String() != tt.expectedStdout {
+			t.Errorf("%v: wrong stdout. want=%q, got=%q", tt.args, tt.expectedStdout, stdout.String())
+		}
+		if !strings.Contains(stderr.String(), tt.expectedStderr) {
+			t.Errorf("%v: stderr does not contain %q. got=%q", tt.args, tt.expectedStderr, stderr.String())
+		}
+	}
+}
@@ -0,0 +1,242 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// 内置函数列表 有序 编译器和虚拟机通过下标引用内置函数 新增内置函数只能追加在末尾
+// 内置函数返回nil表示null 由调用方转换为对应的null对象
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{
+		"len",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError(ArityError, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *Array: // 数组
+				return &Integer{
+					Value: int64(len(arg.Elements)),
+				}
+			case *String: // 字符串长度是字符(Unicode码点)的个数 不是字节数 len("中文") 是 2
+				return &Integer{
+					Value: int64(utf8.RuneCountInString(arg.Value)),
+				}
+			default:
+				return newError(TypeError, "argument to `len` not supported, got %s", args[0].Type())
+			}
+		},
+		},
+	},
+	{
+		"first",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 { // 参数长度校验
+				return newError(ArityError, "wrong number of arguments.got=%d, want=1", len(args))
+			}
+			if args[0].Type() != ARRAY_OBJ { // 不是数组 不支持first函数
+				return newError(TypeError, "argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+			arr := args[0].(*Array)
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
+			return nil
+		},
+		},
+	},
+	{
+		"last",
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 { // 参数长度校验
+				return newError(ArityError, "wrong number of arguments.got=%d, want=1", len(args))
+			}
+			if args[0].Type() != ARRAY_OBJ { // 不是数组 不支持first函数
+				return newError(TypeError, "argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+			return nil
+		},
+		},
+	},
+	{
+		"rust", // 返回除去第一个元素的新数组 不会修改原数组
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 { // 参数长度校验
+				return newError(ArityError, "wrong number of arguments.got=%d, want=1", len(args))
+			}
+			if args[0].Type() != ARRAY_OBJ { // 不是数组 不支持first函数
+				return newError(TypeError, "argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				newElements := make([]Object, length-1, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &Array{
+					Elements: newElements,
+				}
+			}
+			return nil
+		},
+		},
+	},
+	{
+		"push", // 向数组追加元素 返回是追加元素后的数组 原数组是不变的 数组具有不可变性
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 2 { // 参数长度校验
+				return newError(ArityError, "wrong number of arguments.got=%d, want=2", len(args))
+			}
+			if args[0].Type() != ARRAY_OBJ { // 不是数组 不支持first函数
+				return newError(TypeError, "argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			newElements := make([]Object, length+1, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
+			return &Array{
+				Elements: newElements,
+			}
+		},
+		},
+	},
+	{
+		"pop", // 向数组弹出最后一个元素 返回是弹出元素后的数组 原数组是不变的 数组具有不可变性
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 { // 参数长度校验
+				return newError(ArityError, "wrong number of arguments.got=%d, want=1", len(args))
+			}
+			if args[0].Type() != ARRAY_OBJ { // 不是数组 不支持first函数
+				return newError(TypeError, "argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length >= 1 {
+				newElements := make([]Object, length-1, length-1)
+				copy(newElements, arr.Elements[0:length-1])
+				return &Array{
+					Elements: newElements,
+				}
+			}
+			return &Array{} // 空数组没有元素 返回值还是空数组就行
+		},
+		},
+	},
+	{
+		"puts", // 打印参数 输出结果是每个参数独占一行
+		&Builtin{Fn: func(args ...Object) Object {
+			for _, arg := range args {
+				fmt.Println(arg.Inspect())
+			}
+			return nil // 不产生值 只是消费值
+		},
+		},
+	},
+	{
+		"int", // 转换为整数 浮点数向0取整 字符串支持 0x 0o 0b 前缀 超出int64范围时是大整数
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError(ArityError, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *Integer, *BigInt:
+				return arg
+			case *Float:
+				return floatToInteger("int", math.Trunc(arg.Value))
+			case *String:
+				value, ok := new(big.Int).SetString(strings.TrimSpace(arg.Value), 0)
+				if !ok {
+					return newError(ValueError, "could not parse %q as integer", arg.Value)
+				}
+				return NewInteger(value)
+			default:
+				return newError(TypeError, "argument to `int` not supported, got %s", args[0].Type())
+			}
+		},
+		},
+	},
+	{
+		"float", // 转换为浮点数
+		&Builtin{Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return newError(ArityError, "wrong number of arguments. got=%d, want=1", len(args))
+			}
+			switch arg := args[0].(type) {
+			case *Integer:
+				return &Float{Value: float64(arg.Value)}
+			case *BigInt:
+				return &Float{Value: arg.Float64()}
+			case *Float:
+				return arg
+			case *String:
+				value, err := strconv.ParseFloat(strings.TrimSpace(arg.Value), 64)
+				if err != nil {
+					return newError(ValueError, "could not parse %q as float", arg.Value)
+				}
+				return &Float{Value: value}
+			default:
+				return newError(TypeError, "argument to `float` not supported, got %s", args[0].Type())
+			}
+		},
+		},
+	},
+	{"floor", roundingBuiltin("floor", math.Floor)}, // 向下取整
+	{"ceil", roundingBuiltin("ceil", math.Ceil)},    // 向上取整
+	{"round", roundingBuiltin("round", math.Round)}, // 四舍五入 .5 远离0 round(-2.5) 是 -3
+}
+
+// 取整函数 参数是数字 结果是整数
+func roundingBuiltin(name string, fn func(float64) float64) *Builtin {
+	return &Builtin{Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return newError(ArityError, "wrong number of arguments. got=%d, want=1", len(args))
+		}
+		switch arg := args[0].(type) {
+		case *Integer, *BigInt:
+			return arg
+		case *Float:
+			return floatToInteger(name, fn(arg.Value))
+		default:
+			return newError(TypeError, "argument to `%s` must be INTEGER or FLOAT, got %s", name, args[0].Type())
+		}
+	}}
+}
+
+// 已经取整的浮点数转换为整数 超出int64范围时是大整数 NaN和Inf无法转换
+func floatToInteger(name string, value float64) Object {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return newError(DefaultErrorKind, "argument to `%s` out of integer range: %s", name, (&Float{Value: value}).Inspect())
+	}
+	if value >= math.MinInt64 && value < math.MaxInt64 {
+		return &Integer{Value: int64(value)}
+	}
+	integer, _ := big.NewFloat(value).Int(nil)
+	return NewInteger(integer)
+}
+
+// 根据名称获取内置函数
+func GetBuiltinByName(name string) *Builtin {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin
+		}
+	}
+	return nil
+}
+
+func newError(kind, format string, a ...any) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...), Kind: kind}
+}
@@ -1,14 +1,33 @@
 package object
 
-// 创建环境对象
+import "sort"
+
+// 默认的最大调用深度
+const DefaultMaxCallDepth = 10000
+
+// 创建环境对象 每个最外层的环境有自己的调用栈
 func NewEnvironment() *Environment {
 	s := make(map[string]Object)
-	return &Environment{store: s}
+	return &Environment{store: s, calls: &CallStack{MaxDepth: DefaultMaxCallDepth}}
 }
 
 type Environment struct {
 	store map[string]Object // 存储已经定义的属性
 	outer *Environment      // 外层环境
+	calls *CallStack        // 和外层环境共享的调用栈
+}
+
+// 函数调用栈 同一次求值中的环境共享 求值器用来限制递归的深度
+// 修改MaxDepth可以为这次求值设置不同的限制 CheckedArithmetic同样只影响这次求值
+type CallStack struct {
+	MaxDepth          int     // 最大调用深度 超过时求值器返回错误
+	Frames            []Frame // 正在执行的函数调用 最外层的调用在前
+	CheckedArithmetic bool    // 整数运算的结果超出int64范围时返回错误 而不是提升为大整数
+}
+
+// 环境所在的调用栈
+func (e *Environment) CallStack() *CallStack {
+	return e.calls
 }
 
 func (e *Environment) Get(name string) (Object, bool) {
@@ -24,9 +43,33 @@ func (e *Environment) Set(name string, val Object) Object {
 	return val
 }
 
+// 给已有的绑定赋值 沿着外层环境查找 在定义绑定的环境中原地修改
+// 闭包因此可以修改外层作用域中的变量 没有找到绑定时返回false
+func (e *Environment) Assign(name string, val Object) (Object, bool) {
+	if _, ok := e.store[name]; ok {
+		e.store[name] = val
+		return val, true
+	}
+	if e.outer != nil {
+		return e.outer.Assign(name, val)
+	}
+	return nil, false
+}
+
 // 创建函数作用域 环境
 func NewEnclosedEnvironment(outer *Environment) *Environment {
 	env := NewEnvironment()
 	env.outer = outer
+	env.calls = outer.calls
 	return env
 }
+
+// 当前环境中定义的绑定名称 不包括外层环境 按名称排序
+func (e *Environment) Names() []string {
+	names := make([]string, 0, len(e.store))
+	for name := range e.store {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
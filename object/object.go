@@ -4,7 +4,13 @@ import (
 	"bytes"
 	"fmt"
 	"hash/fnv"
+	"math"
+	"math/big"
 	"monkey/ast"
+	"monkey/code"
+	"monkey/token"
+	"sort"
+	"strconv"
 	"strings"
 )
 
@@ -12,9 +18,13 @@ type ObjectType string // 对象类型
 
 const (
 	INTEGER_OBJ      = "INTEGER"
+	BIGINT_OBJ       = "BIGINT"
+	FLOAT_OBJ        = "FLOAT"
 	BOOLEAN_OBJ      = "BOOLEAN"
 	NULL_OBJ         = "NULL"
 	RETURN_VALUE_OBJ = "RETURN_VALUE"
+	BREAK_OBJ        = "BREAK"
+	CONTINUE_OBJ     = "CONTINUE"
 	ERROR_OBJ        = "ERROR"
 	FUNCTION_OBJ     = "FUNCTION"
 	STRING_OBJ       = "STRING"
@@ -23,6 +33,9 @@ const (
 	HASH_OBJ         = "HASH"
 	QUOTE_OBJ        = "QUOTE"
 	MACRO_OBJ        = "MACRO"
+
+	COMPILED_FUNCTION_OBJ = "COMPILED_FUNCTION"
+	CLOSURE_OBJ           = "CLOSURE"
 )
 
 // 对象表示
@@ -44,6 +57,67 @@ func (i *Integer) Type() ObjectType {
 	return INTEGER_OBJ
 }
 
+// 大整数 超出int64范围的整数
+// 能用int64表示的值总是Integer 所以同一个数只有一种表示 运算结果用NewInteger转换
+type BigInt struct {
+	Value *big.Int // 不会被修改 运算总是创建新的值
+}
+
+func (b *BigInt) Inspect() string {
+	return b.Value.String()
+}
+
+func (b *BigInt) Type() ObjectType {
+	return BIGINT_OBJ
+}
+
+// 最接近的浮点数 超出浮点数范围时是Inf
+func (b *BigInt) Float64() float64 {
+	f, _ := new(big.Float).SetInt(b.Value).Float64()
+	return f
+}
+
+// 整数对象 能用int64表示时是Integer 否则是BigInt
+func NewInteger(value *big.Int) Object {
+	if value.IsInt64() {
+		return &Integer{Value: value.Int64()}
+	}
+	return &BigInt{Value: value}
+}
+
+// Integer或者BigInt的值 不是整数时返回nil
+func ToBigInt(obj Object) *big.Int {
+	switch obj := obj.(type) {
+	case *Integer:
+		return big.NewInt(obj.Value)
+	case *BigInt:
+		return obj.Value
+	}
+	return nil
+}
+
+// 浮点数
+type Float struct {
+	Value float64
+}
+
+// 整数值的浮点数保留 .0 和整数区分开 很大或者很小的数使用科学计数法
+func (f *Float) Inspect() string {
+	format := byte('f')
+	if abs := math.Abs(f.Value); abs >= 1e21 || abs != 0 && abs < 1e-6 {
+		format = 'e'
+	}
+	s := strconv.FormatFloat(f.Value, format, -1, 64)
+	if strings.ContainsAny(s, ".eIN") { // 已经有小数点 指数 或者是 Inf NaN
+		return s
+	}
+	return s + ".0"
+}
+
+func (f *Float) Type() ObjectType {
+	return FLOAT_OBJ
+}
+
 // 布尔值
 type Boolean struct {
 	Value bool
@@ -81,21 +155,136 @@ func (rv *ReturnValue) Type() ObjectType {
 	return RETURN_VALUE_OBJ
 }
 
+// break和continue 和返回值一样向外传递 直到所在的循环 不会成为表达式的值
+type Break struct{}
+
+func (b *Break) Inspect() string {
+	return "break"
+}
+
+func (b *Break) Type() ObjectType {
+	return BREAK_OBJ
+}
+
+type Continue struct{}
+
+func (c *Continue) Inspect() string {
+	return "continue"
+}
+
+func (c *Continue) Type() ObjectType {
+	return CONTINUE_OBJ
+}
+
+// 错误的种类 保存在Error.Kind中 catch时可以读取
+const (
+	DefaultErrorKind = "Error"          // 没有指定种类的错误 比如throw抛出的字符串
+	TypeError        = "TypeError"      // 操作数或者实参的类型不支持
+	ValueError       = "ValueError"     // 操作数的类型正确但是值不合法 比如负数的移位位数
+	NameError        = "NameError"      // 标识符没有定义或者不能赋值
+	IndexError       = "IndexError"     // 下标越界
+	ArityError       = "ArityError"     // 实参个数和形参个数不一致
+	DivisionByZero   = "DivisionByZero" // 除数为0
+	OverflowError    = "OverflowError"  // 运算结果超出范围 比如检查整数溢出时
+	RecursionError   = "RecursionError" // 函数调用的深度超过限制
+)
+
 // 错误对象
 type Error struct {
 	Message string
+	Kind    string         // 错误的种类 catch时可以读取 为空时是DefaultErrorKind
+	Pos     token.Position // 发生错误的源码位置
+	Trace   []Frame        // 出错时的调用链 错误向外传递时依次添加 最内层的调用在前
+}
+
+// Python风格的调用链 最近的调用在最后 每一行是一个位置和它所在的函数 没有调用链时为空
+// 连续重复超过3次的行只显示一次重复的次数
+func (e *Error) Traceback() string {
+	if len(e.Trace) == 0 {
+		return ""
+	}
+	lines := []string{}
+	function := "<program>"
+	for i := len(e.Trace) - 1; i >= 0; i-- {
+		lines = append(lines, tracebackLine(e.Trace[i].Pos, function))
+		function = e.Trace[i].function()
+	}
+	lines = append(lines, tracebackLine(e.Pos, function))
+
+	var out bytes.Buffer
+	out.WriteString("Traceback (most recent call last):\n")
+	for i := 0; i < len(lines); {
+		n := 1
+		for i+n < len(lines) && lines[i+n] == lines[i] {
+			n++
+		}
+		for j := 0; j < n && j < 3; j++ {
+			out.WriteString(lines[i] + "\n")
+		}
+		if n > 3 {
+			fmt.Fprintf(&out, "  [Previous line repeated %d more times]\n", n-3)
+		}
+		i += n
+	}
+	return out.String()
+}
+
+func tracebackLine(pos token.Position, function string) string {
+	if pos.Filename == "" {
+		return fmt.Sprintf("  line %d, column %d, in %s", pos.Line, pos.Column, function)
+	}
+	return fmt.Sprintf("  File %q, line %d, column %d, in %s", pos.Filename, pos.Line, pos.Column, function)
+}
+
+// 调用栈中的一帧
+type Frame struct {
+	Function string         // 函数名 通过let绑定的函数才有名字
+	Pos      token.Position // 调用的位置
+	NumArgs  int            // 实参个数
+	Tail     bool           // 是否执行过尾调用 尾调用复用这一帧 函数名和实参个数仍然是最初调用的
+	TailCall string         // 最后一次尾调用的函数名
+}
+
+// 函数名和实参个数 f(2 args) 执行过尾调用时是 f(2 args) (tail call to g)
+func (f Frame) function() string {
+	name := displayName(f.Function)
+	if f.NumArgs == 1 {
+		name += "(1 arg)"
+	} else {
+		name = fmt.Sprintf("%s(%d args)", name, f.NumArgs)
+	}
+	if f.Tail {
+		name += " (tail call to " + displayName(f.TailCall) + ")"
+	}
+	return name
+}
+
+func displayName(name string) string {
+	if name == "" {
+		return "<anonymous>"
+	}
+	return name
+}
+
+// name(n args) called at file:line:col 匿名函数显示为<anonymous>
+func (f Frame) String() string {
+	return f.function() + " called at " + f.Pos.String()
 }
 
 func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return "ERROR: " + e.Pos.String() + ": " + e.Message
+	}
 	return "ERROR: " + e.Message
 }
 
 func (e *Error) Type() ObjectType {
-	return RETURN_VALUE_OBJ
+	return ERROR_OBJ
 }
 
 // 函数对象
 type Function struct {
+	Name       string              // 通过let绑定时的名称 用于调用链 匿名函数为空
 	Parameters []*ast.Identifier   // 没有参数 没办法对函数体求值
 	Body       *ast.BlockStatement // 没有函数体 没办法对函数求值
 	Env        *Environment        // 环境 函数上下文 实现闭包！
@@ -198,6 +387,31 @@ func (i *Integer) HashKey() HashKey {
 		Value: uint64(i.Value),
 	}
 }
+
+// 大整数和Integer不会相等 使用十进制形式的hash值
+func (b *BigInt) HashKey() HashKey {
+	h := fnv.New64a()
+	h.Write(b.Value.Append(nil, 10))
+	return HashKey{
+		Type:  b.Type(),
+		Value: h.Sum64(),
+	}
+}
+
+// 整数值的浮点数和对应的整数是同一个key {1: "a"}[1.0] 可以取到值 1e20 和对应的大整数也一样
+func (f *Float) HashKey() HashKey {
+	if f.Value == math.Trunc(f.Value) && !math.IsInf(f.Value, 0) {
+		if f.Value >= math.MinInt64 && f.Value < math.MaxInt64 {
+			return (&Integer{Value: int64(f.Value)}).HashKey()
+		}
+		value, _ := big.NewFloat(f.Value).Int(nil)
+		return (&BigInt{Value: value}).HashKey()
+	}
+	return HashKey{
+		Type:  f.Type(),
+		Value: math.Float64bits(f.Value),
+	}
+}
 func (s *String) HashKey() HashKey {
 	h := fnv.New64a()
 	h.Write([]byte(s.Value))
@@ -222,6 +436,58 @@ func (h *Hash) Type() ObjectType {
 	return HASH_OBJ
 }
 
+// 排好序的键 map的遍历顺序不固定 for-in循环按这个顺序访问
+// 布尔值在前 然后是数字(整数 大整数和浮点数一起按大小) 最后是字符串(按字节)
+func (h *Hash) SortedKeys() []Object {
+	keys := make([]Object, 0, len(h.Pairs))
+	for _, pair := range h.Pairs {
+		keys = append(keys, pair.Key)
+	}
+	sort.Slice(keys, func(i, j int) bool { return keyLess(keys[i], keys[j]) })
+	return keys
+}
+
+func keyLess(a, b Object) bool {
+	if ra, rb := keyRank(a), keyRank(b); ra != rb {
+		return ra < rb
+	}
+	switch a := a.(type) {
+	case *Boolean:
+		return !a.Value && b.(*Boolean).Value
+	case *String:
+		return a.Value < b.(*String).Value
+	case *Integer:
+		if b, ok := b.(*Integer); ok { // 大整数转成浮点数会丢失精度
+			return a.Value < b.Value
+		}
+	}
+	if x, y := ToBigInt(a), ToBigInt(b); x != nil && y != nil {
+		return x.Cmp(y) < 0
+	}
+	return keyNumber(a) < keyNumber(b)
+}
+
+func keyRank(obj Object) int {
+	switch obj.(type) {
+	case *Boolean:
+		return 0
+	case *Integer, *BigInt, *Float:
+		return 1
+	default:
+		return 2
+	}
+}
+
+func keyNumber(obj Object) float64 {
+	switch obj := obj.(type) {
+	case *Integer:
+		return float64(obj.Value)
+	case *BigInt:
+		return obj.Float64()
+	}
+	return obj.(*Float).Value
+}
+
 func (h *Hash) Inspect() string {
 	var out bytes.Buffer
 	pairs := []string{}
@@ -272,3 +538,39 @@ func (m *Macro) Inspect() string {
 	out.WriteString("\n}")
 	return out.String()
 }
+
+// 编译后的函数 字节码指令 由编译器放入常量池
+type CompiledFunction struct {
+	Instructions  code.Instructions
+	Positions     []token.Position // 每个字节对应的源码位置 和Instructions等长 用于错误信息
+	NumLocals     int              // 局部绑定的个数 虚拟机据此在栈上预留空间
+	NumParameters int              // 参数个数 调用时校验实参个数
+	Name          string           // 通过let绑定时的名称 用于错误的调用链
+	Source        string           // 函数的源码 格式和求值器中Function的Inspect()一样
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return COMPILED_FUNCTION_OBJ
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// 闭包 编译后的函数加上捕获的自由变量 虚拟机中所有的函数都是闭包
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Type() ObjectType {
+	return CLOSURE_OBJ
+}
+
+// 和求值器中的函数一样显示源码 手工构造的没有源码时显示地址
+func (c *Closure) Inspect() string {
+	if c.Fn.Source != "" {
+		return c.Fn.Source
+	}
+	return fmt.Sprintf("Closure[%p]", c)
+}
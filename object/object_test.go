@@ -2,7 +2,12 @@
 
 package object
 
-import "testing"
+import (
+	"math"
+	"math/big"
+	"monkey/token"
+	"testing"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := &String{Value: "Hello World"}
@@ -22,3 +27,187 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestFloatInspect(t *testing.T) {
+	tests := []struct {
+		value    float64
+		expected string
+	}{
+		{3.14, "3.14"},
+		{2, "2.0"},
+		{-0.5, "-0.5"},
+		{1e6, "1000000.0"},
+		{1e21, "1e+21"},
+		{1e-9, "1e-09"},
+		{0.1, "0.1"},
+		{math.Inf(1), "+Inf"},
+		{math.NaN(), "NaN"},
+	}
+
+	for _, tt := range tests {
+		if got := (&Float{Value: tt.value}).Inspect(); got != tt.expected {
+			t.Errorf("Inspect(%v) wrong. want=%q, got=%q", tt.value, tt.expected, got)
+		}
+	}
+}
+
+func TestFloatHashKey(t *testing.T) {
+	if (&Float{Value: 1.5}).HashKey() != (&Float{Value: 1.5}).HashKey() {
+		t.Errorf("floats with same value have different hash keys")
+	}
+	if (&Float{Value: 1.5}).HashKey() == (&Float{Value: 2.5}).HashKey() {
+		t.Errorf("floats with different values have same hash keys")
+	}
+	// 整数值的浮点数和整数是同一个key
+	if (&Float{Value: 2}).HashKey() != (&Integer{Value: 2}).HashKey() {
+		t.Errorf("2.0 and 2 have different hash keys")
+	}
+	if (&Float{Value: 0.5}).HashKey() == (&Integer{Value: 0}).HashKey() {
+		t.Errorf("0.5 and 0 have same hash keys")
+	}
+}
+
+func TestNewInteger(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected Object
+	}{
+		{"0", &Integer{Value: 0}},
+		{"9223372036854775807", &Integer{Value: math.MaxInt64}},
+		{"-9223372036854775808", &Integer{Value: math.MinInt64}},
+		{"9223372036854775808", &BigInt{}},
+		{"-9223372036854775809", &BigInt{}},
+	}
+
+	for _, tt := range tests {
+		value, _ := new(big.Int).SetString(tt.value, 10)
+		obj := NewInteger(value)
+		if obj.Type() != tt.expected.Type() || obj.Inspect() != tt.value {
+			t.Errorf("NewInteger(%s) wrong. got=%s %s", tt.value, obj.Type(), obj.Inspect())
+		}
+	}
+}
+
+func TestBigIntHashKey(t *testing.T) {
+	two64 := new(big.Int).Lsh(big.NewInt(1), 64)
+	if (&BigInt{Value: two64}).HashKey() != (&BigInt{Value: new(big.Int).Set(two64)}).HashKey() {
+		t.Errorf("big integers with same value have different hash keys")
+	}
+	if (&BigInt{Value: two64}).HashKey() == (&BigInt{Value: new(big.Int).Neg(two64)}).HashKey() {
+		t.Errorf("big integers with different values have same hash keys")
+	}
+	// 整数值的浮点数和对应的大整数是同一个key
+	if (&Float{Value: 18446744073709551616}).HashKey() != (&BigInt{Value: two64}).HashKey() {
+		t.Errorf("18446744073709551616.0 and 18446744073709551616 have different hash keys")
+	}
+	if (&Float{Value: math.Inf(1)}).HashKey().Type != FLOAT_OBJ {
+		t.Errorf("+Inf should hash as a float")
+	}
+}
+
+func TestHashSortedKeys(t *testing.T) {
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	for _, key := range []Object{
+		&String{Value: "b"},
+		&Integer{Value: 10},
+		&Boolean{Value: true},
+		&Float{Value: 2.5},
+		&String{Value: "a"},
+		&Integer{Value: -1},
+		&Boolean{Value: false},
+		&Integer{Value: 9007199254740993}, // 超过浮点数精度的整数按整数比较
+		&Integer{Value: 9007199254740992},
+		&BigInt{Value: new(big.Int).Lsh(big.NewInt(1), 64)},
+		&BigInt{Value: new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 64))},
+		&Float{Value: 1e30},
+	} {
+		hash.Pairs[key.(Hashable).HashKey()] = HashPair{Key: key, Value: key}
+	}
+
+	expected := []string{"false", "true", "-18446744073709551616", "-1", "2.5", "10", "9007199254740992", "9007199254740993",
+		"18446744073709551616", "1e+30", "a", "b"}
+	keys := hash.SortedKeys()
+	if len(keys) != len(expected) {
+		t.Fatalf("expected %d keys, got %d", len(expected), len(keys))
+	}
+	for i, key := range keys {
+		if key.Inspect() != expected[i] {
+			t.Errorf("keys[%d] - expected %s, got %s", i, expected[i], key.Inspect())
+		}
+	}
+}
+
+func TestEnvironmentCallStack(t *testing.T) {
+	outer := NewEnvironment()
+	if outer.CallStack().MaxDepth != DefaultMaxCallDepth {
+		t.Errorf("wrong default max depth. got=%d", outer.CallStack().MaxDepth)
+	}
+	inner := NewEnclosedEnvironment(NewEnclosedEnvironment(outer))
+	if inner.CallStack() != outer.CallStack() {
+		t.Errorf("enclosed environment does not share the call stack")
+	}
+	if NewEnvironment().CallStack() == outer.CallStack() {
+		t.Errorf("new environment shares the call stack")
+	}
+}
+
+func TestFrameString(t *testing.T) {
+	tests := []struct {
+		frame    Frame
+		expected string
+	}{
+		{Frame{Function: "f", Pos: token.Position{Filename: "a.mk", Line: 2, Column: 3}, NumArgs: 2}, "f(2 args) called at a.mk:2:3"},
+		{Frame{Pos: token.Position{Line: 1, Column: 5}, NumArgs: 1}, "<anonymous>(1 arg) called at 1:5"},
+		{Frame{Function: "g", Pos: token.Position{Line: 1, Column: 5}}, "g(0 args) called at 1:5"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.frame.String(); got != tt.expected {
+			t.Errorf("wrong frame. want=%q, got=%q", tt.expected, got)
+		}
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	pos := func(line, column int) token.Position {
+		return token.Position{Filename: "a.mk", Line: line, Column: column}
+	}
+	err := &Error{
+		Message: "identifier not found: x",
+		Pos:     pos(2, 3),
+		Trace: []Frame{
+			{Function: "g", Pos: pos(5, 10), NumArgs: 1},
+			{Function: "", Pos: pos(8, 1), NumArgs: 2},
+		},
+	}
+	expected := `Traceback (most recent call last):
+  File "a.mk", line 8, column 1, in <program>
+  File "a.mk", line 5, column 10, in <anonymous>(2 args)
+  File "a.mk", line 2, column 3, in g(1 arg)
+`
+	if got := err.Traceback(); got != expected {
+		t.Errorf("wrong traceback. want=%q, got=%q", expected, got)
+	}
+
+	if got := (&Error{Message: "m", Pos: pos(1, 1)}).Traceback(); got != "" {
+		t.Errorf("error without trace has traceback %q", got)
+	}
+}
+
+func TestErrorTracebackRepeated(t *testing.T) {
+	err := &Error{Message: "m", Pos: token.Position{Line: 1, Column: 18}}
+	for i := 0; i < 5; i++ {
+		err.Trace = append(err.Trace, Frame{Function: "f", Pos: token.Position{Line: 1, Column: 18}, NumArgs: 1})
+	}
+	err.Trace[len(err.Trace)-1].Pos = token.Position{Line: 2, Column: 2}
+	expected := `Traceback (most recent call last):
+  line 2, column 2, in <program>
+  line 1, column 18, in f(1 arg)
+  line 1, column 18, in f(1 arg)
+  line 1, column 18, in f(1 arg)
+  [Previous line repeated 2 more times]
+`
+	if got := err.Traceback(); got != expected {
+		t.Errorf("wrong traceback. want=%q, got=%q", expected, got)
+	}
+}
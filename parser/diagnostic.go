@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"monkey/token"
+	"monkey/width"
+	"strings"
+)
+
+// 诊断信息的严重程度
+type Severity int
+
+const (
+	SeverityError   Severity = iota // 错误 程序无法执行
+	SeverityWarning                 // 警告 程序可以执行
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityError:
+		return "error"
+	case SeverityWarning:
+		return "warning"
+	default:
+		return "unknown"
+	}
+}
+
+// 错误码 工具可以据此过滤
+const (
+	ErrUnexpectedToken = "E0001" // 不是期望的词法单元
+	ErrNoPrefixParseFn = "E0002" // 词法单元不能作为表达式的开头
+	ErrInvalidInteger  = "E0003" // 整数字面量无法解析
+	ErrInvalidFloat    = "E0004" // 浮点数字面量无法解析
+	// E0005 原来表示整数字面量超出64位整数的范围 现在这样的字面量是大整数
+	// 词法错误
+	ErrUnterminatedString  = "E0006" // 字符串没有结束
+	ErrInvalidEscape       = "E0007" // 不合法的转义字符
+	ErrUnterminatedComment = "E0008" // 块注释没有结束
+	// 语法错误
+	ErrInvalidAssignment    = "E0009" // 赋值的左侧不是标识符或者索引表达式
+	ErrMisplacedLoopControl = "E0010" // break continue不在循环中 或者用在了表达式里
+)
+
+// 源码中的一段区间 [Start, End)
+type Span struct {
+	Start token.Position
+	End   token.Position
+}
+
+// 语法分析的诊断信息
+type Diagnostic struct {
+	Severity Severity
+	Code     string
+	Span     Span
+	Expected token.TokenType // 期望的词法单元类型 没有明确期望时为空
+	Actual   token.TokenType // 实际遇到的词法单元类型
+	Message  string
+}
+
+// file:line:col: message 也就是Errors()返回的格式
+func (d Diagnostic) String() string {
+	return d.Span.Start.String() + ": " + d.Message
+}
+
+// 出错的源码行 下一行在出错的列下面标记 ^
+func (d Diagnostic) Snippet(source string) string {
+	start := d.Span.Start
+	if !start.IsValid() {
+		return ""
+	}
+	lines := strings.Split(source, "\n")
+	if start.Line > len(lines) {
+		return ""
+	}
+	line := []rune(strings.TrimRight(lines[start.Line-1], "\r"))
+	var caret strings.Builder
+	for i := 0; i < start.Column-1 && i < len(line); i++ {
+		if line[i] == '\t' { // 保留制表符 保证^和源码对齐
+			caret.WriteByte('\t')
+		} else { // 列按字符计数 全角字符在终端中占两列
+			caret.WriteString(strings.Repeat(" ", width.Rune(line[i])))
+		}
+	}
+	caret.WriteString("^")
+	if end := d.Span.End; end.Line == start.Line && end.Column-1 <= len(line) && end.Column > start.Column {
+		w := width.String(string(line[start.Column-1 : end.Column-1]))
+		caret.WriteString(strings.Repeat("~", w-1))
+	}
+	return string(line) + "\n" + caret.String()
+}
+
+// 词法单元在源码中的区间 结束位置由词法分析器记录 字符串可以含有转义或者跨行
+func tokenSpan(tok token.Token) Span {
+	return Span{Start: tok.Pos, End: tok.End}
+}
@@ -0,0 +1,76 @@
+package parser
+
+import (
+	"monkey/lexer"
+	"monkey/token"
+	"testing"
+)
+
+func TestDiagnostics(t *testing.T) {
+	input := "let x 5;\nlet y = 0x;"
+
+	p := New(lexer.New(input))
+	p.ParseProgram()
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 2 {
+		t.Fatalf("wrong number of diagnostics. want=2, got=%d (%v)", len(diagnostics), p.Errors())
+	}
+
+	unexpected := diagnostics[0]
+	if unexpected.Severity != SeverityError || unexpected.Code != ErrUnexpectedToken {
+		t.Errorf("wrong severity or code. got=%s %s", unexpected.Severity, unexpected.Code)
+	}
+	if unexpected.Expected != token.ASSIGN || unexpected.Actual != token.INT {
+		t.Errorf("wrong expected/actual. got=%s/%s", unexpected.Expected, unexpected.Actual)
+	}
+	if unexpected.Span.Start.Column != 7 || unexpected.Span.End.Column != 8 {
+		t.Errorf("wrong span. got=%+v", unexpected.Span)
+	}
+
+	invalid := diagnostics[1]
+	if invalid.Code != ErrInvalidInteger || invalid.Expected != "" || invalid.Actual != token.INT {
+		t.Errorf("wrong diagnostic. got=%+v", invalid)
+	}
+	if invalid.Span.Start.Line != 2 || invalid.Span.Start.Column != 11 {
+		t.Errorf("wrong span. got=%+v", invalid.Span)
+	}
+
+	// Errors() 是 Diagnostics() 的字符串形式
+	errors := p.Errors()
+	if errors[0] != "1:7: expected next token to be =, got INT instead." {
+		t.Errorf("wrong error string. got=%q", errors[0])
+	}
+}
+
+func TestDiagnosticSnippet(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x 5;", "let x 5;\n      ^"},
+		{"let a = 1;\n\tlet b = 1 + ;", "\tlet b = 1 + ;\n\t            ^"},
+		{"add(1 \"long\");", "add(1 \"long\");\n      ^~~~~~"},
+		// 汉字在终端中占两列 ^ 和 ~ 按显示宽度对齐
+		{"let 名字 值;", "let 名字 值;\n         ^~"},
+		{"puts(\"猴子\" 1);", "puts(\"猴子\" 1);\n            ^"},
+		{"f(1 \"中\\n\");", "f(1 \"中\\n\");\n    ^~~~~~"},
+		// 区间是源码中的字符串 不是解码后的值
+		{"let \"\\u{1F600}\\u{41}\" = 1", "let \"\\u{1F600}\\u{41}\" = 1\n    ^~~~~~~~~~~~~~~~~"},
+		{"f(1 `a\nb`)", "f(1 `a\n    ^"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) == 0 {
+			t.Errorf("expected diagnostics for %q", tt.input)
+			continue
+		}
+		if got := diagnostics[0].Snippet(tt.input); got != tt.expected {
+			t.Errorf("wrong snippet.\nwant=%q\ngot =%q", tt.expected, got)
+		}
+	}
+}
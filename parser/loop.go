@@ -0,0 +1,95 @@
+package parser
+
+import (
+	"monkey/ast"
+	"monkey/token"
+)
+
+// break continue所在的上下文
+type loopState int
+
+const (
+	outsideLoop   loopState = iota
+	insideLoop              // 可以跳出的循环中
+	insideFinally           // finally块中 块中的循环除外
+)
+
+// 检查break和continue的位置 解析完一条顶层语句之后进行
+// 它们只能出现在循环中 并且只能作为语句使用: 直接在循环体中 或者在作为语句的if try表达式的块中
+// 不能用在表达式里面 比如 1 + if (x) { break } 否则跳出循环时表达式只求了一半
+// 函数体是新的上下文 函数不能跳出定义它的循环 finally块也不能跳出外面的循环
+func (p *Parser) checkLoopControl(node ast.Node, loop loopState, isStatement bool) {
+	switch node := node.(type) {
+	case *ast.BadStatement:
+		// 已经报告过语法错误
+	case *ast.BreakStatement:
+		p.checkLoopControlStatement(node.Token, loop, isStatement)
+	case *ast.ContinueStatement:
+		p.checkLoopControlStatement(node.Token, loop, isStatement)
+	case *ast.BlockStatement:
+		for _, s := range node.Statements {
+			p.checkLoopControl(s, loop, isStatement)
+		}
+	case *ast.ExpressionStatement:
+		if ie, ok := node.Expression.(*ast.IfExpression); ok { // 作为语句的if 分支仍然是语句的位置
+			p.checkLoopControl(ie.Condition, loop, false)
+			p.checkLoopControl(ie.Consequence, loop, isStatement)
+			if ie.Alternative != nil {
+				p.checkLoopControl(ie.Alternative, loop, isStatement)
+			}
+			return
+		}
+		if te, ok := node.Expression.(*ast.TryExpression); ok {
+			p.checkLoopControl(te.Block, loop, isStatement)
+			if te.Catch != nil {
+				p.checkLoopControl(te.Catch, loop, isStatement)
+			}
+			if te.Finally != nil {
+				p.checkLoopControl(te.Finally, insideFinally, isStatement)
+			}
+			return
+		}
+		p.checkLoopControl(node.Expression, loop, false)
+	case *ast.WhileStatement:
+		p.checkLoopControl(node.Condition, loop, false)
+		p.checkLoopControl(node.Body, insideLoop, true)
+	case *ast.ForStatement:
+		p.checkLoopControl(node.Iterable, loop, false)
+		p.checkLoopControl(node.Body, insideLoop, true)
+	case *ast.FunctionLiteral:
+		p.checkLoopControl(node.Body, outsideLoop, true)
+	case *ast.MacroLiteral:
+		p.checkLoopControl(node.Body, outsideLoop, true)
+	default:
+		// 其余节点的子节点都在表达式的位置
+		ast.Inspect(node, func(child ast.Node) bool {
+			if child == node {
+				return true
+			}
+			p.checkLoopControl(child, loop, false)
+			return false
+		})
+	}
+}
+
+func (p *Parser) checkLoopControlStatement(tok token.Token, loop loopState, isStatement bool) {
+	var msg string
+	switch {
+	case loop == outsideLoop:
+		msg = tok.Literal + " outside loop"
+	case loop == insideFinally:
+		msg = tok.Literal + " cannot be used inside finally"
+	case !isStatement:
+		msg = tok.Literal + " cannot be used inside an expression"
+	default:
+		return
+	}
+	// 语法上没有错误 不需要错误恢复 继续检查后面的语句
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Severity: SeverityError,
+		Code:     ErrMisplacedLoopControl,
+		Span:     tokenSpan(tok),
+		Actual:   tok.Type,
+		Message:  msg,
+	})
+}
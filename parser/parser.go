@@ -1,11 +1,14 @@
 package parser
 
 import (
+	"errors"
 	"fmt"
+	"math/big"
 	"monkey/ast"
 	"monkey/lexer"
 	"monkey/token"
 	"strconv"
+	"strings"
 )
 
 // 解析函数类型
@@ -15,31 +18,58 @@ type (
 	// TODO 支持后缀表达式？
 )
 
-// 表达式优先级 从上到下依次递增
+// 表达式优先级 从上到下依次递增 和C语言的优先级一致
 const (
 	_ int = iota // 空白标识符
 	LOWEST
+	ASSIGN      // = += -= *= /= %= **= 右结合
+	LOGICAL_OR  // ||
+	LOGICAL_AND // &&
+	BIT_OR      // |
+	BIT_XOR     // ^
+	BIT_AND     // &
 	EQUALS      // ==
-	LESSGREATER // > or <
+	LESSGREATER // > or < or >= or <= or in
+	SHIFT       // << >>
 	SUM         // + -
-	PRODUCT     // * /
-	PREFIX      // -X or !X
+	PRODUCT     // * / %
+	POWER       // ** 右结合 -2 ** 2 是 (-2) ** 2
+	PREFIX      // -X or !X or ~X
 	CALL        // add()
 	INDEX       // arr[index] 索引运算符 优先级最高
 )
 
 // 优先级表  词法类型关联对应的优先级
 var precedences = map[token.TokenType]int{
-	token.EQ:       EQUALS,
-	token.NOT_EQ:   EQUALS,
-	token.LT:       LESSGREATER,
-	token.GT:       LESSGREATER,
-	token.PLUS:     SUM,
-	token.MINUS:    SUM,
-	token.SLASH:    PRODUCT,
-	token.ASTERISK: PRODUCT,
-	token.LPAREN:   CALL,  // 函数调用表达式 具备最高优先级
-	token.LBRACKET: INDEX, // [ 索引表达式访问优先级
+	token.ASSIGN:          ASSIGN,
+	token.PLUS_ASSIGN:     ASSIGN,
+	token.MINUS_ASSIGN:    ASSIGN,
+	token.ASTERISK_ASSIGN: ASSIGN,
+	token.SLASH_ASSIGN:    ASSIGN,
+	token.PERCENT_ASSIGN:  ASSIGN,
+	token.POWER_ASSIGN:    ASSIGN,
+	token.EQ:              EQUALS,
+	token.NOT_EQ:          EQUALS,
+	token.LT:              LESSGREATER,
+	token.GT:              LESSGREATER,
+	token.LT_EQ:           LESSGREATER,
+	token.GT_EQ:           LESSGREATER,
+	token.IN:              LESSGREATER, // "a" in s 和比较运算的优先级相同
+	token.OR:              LOGICAL_OR,
+	token.AND:             LOGICAL_AND,
+	token.BIT_OR:          BIT_OR,
+	token.BIT_XOR:         BIT_XOR,
+	token.BIT_AND:         BIT_AND,
+	token.SHL:             SHIFT,
+	token.SHR:             SHIFT,
+	token.PLUS:            SUM,
+	token.MINUS:           SUM,
+	token.SLASH:           PRODUCT,
+	token.ASTERISK:        PRODUCT,
+	token.PERCENT:         PRODUCT,
+	token.POWER:           POWER,
+	token.LPAREN:          CALL,  // 函数调用表达式 具备最高优先级
+	token.LBRACKET:        INDEX, // [ 索引表达式访问优先级
 }
 
 // Parser 语法解析器对象
@@ -47,7 +77,10 @@ type Parser struct {
 	l              *lexer.Lexer                      // 词法解析对象
 	curToken       token.Token                       // 当前的token 词法单元
 	peekToken      token.Token                       // 偷看的下一个token 如果上一个token的信息不够做决策，需要根据该字段来做决策
-	errors         []string                          // 错误
+	diagnostics    []Diagnostic                      // 错误
+	panicking      bool                              // 当前语句已经出错 在错误恢复之前不再记录后续错误 避免连锁错误
+	braceDepth     int                               // 截止到curToken 尚未闭合的 { 个数 错误恢复时判断块的结束
+	lexerErrors    int                               // 已经转换为诊断信息的词法错误个数
 	prefixParseFns map[token.TokenType]prefixParseFn // 词法单元类型关联对应的解析函数
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -55,19 +88,22 @@ type Parser struct {
 // New 创建一个parser
 func New(l *lexer.Lexer) *Parser {
 	p := &Parser{
-		l:      l,
-		errors: []string{},
+		l:           l,
+		diagnostics: []Diagnostic{},
 	}
 	// 关联解析函数
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.IDENT, p.parseIndentifier)        // 标识符
 	p.registerPrefix(token.INT, p.parseIntegerLiteral)       // 数字
+	p.registerPrefix(token.FLOAT, p.parseFloatLiteral)       // 浮点数
 	p.registerPrefix(token.BANG, p.parsePrefixExpression)    // / !
 	p.registerPrefix(token.MINUS, p.parsePrefixExpression)   // -
+	p.registerPrefix(token.BIT_NOT, p.parsePrefixExpression) // ~ 按位取反
 	p.registerPrefix(token.TRUE, p.parseBoolean)             // true
 	p.registerPrefix(token.FALSE, p.parseBoolean)            // false
 	p.registerPrefix(token.LPAREN, p.parseGroupedExpression) // (
 	p.registerPrefix(token.IF, p.parseIfExpression)          // if
+	p.registerPrefix(token.TRY, p.parseTryExpression)
 	p.registerPrefix(token.FUNCTION, p.parseFunctionLiteral) // fn
 	p.registerPrefix(token.STRING, p.parseStringLiteral)     // string
 	p.registerPrefix(token.LBRACKET, p.parseArrayLiteral)    // array [
@@ -79,10 +115,29 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.MINUS, p.parseInfixExpression)
 	p.registerInfix(token.SLASH, p.parseInfixExpression)
 	p.registerInfix(token.ASTERISK, p.parseInfixExpression)
+	p.registerInfix(token.PERCENT, p.parseInfixExpression)
+	p.registerInfix(token.POWER, p.parseInfixExpression)
+	p.registerInfix(token.IN, p.parseInfixExpression)
 	p.registerInfix(token.EQ, p.parseInfixExpression)
 	p.registerInfix(token.NOT_EQ, p.parseInfixExpression)
 	p.registerInfix(token.LT, p.parseInfixExpression)
 	p.registerInfix(token.GT, p.parseInfixExpression)
+	p.registerInfix(token.LT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.GT_EQ, p.parseInfixExpression)
+	p.registerInfix(token.AND, p.parseInfixExpression) // && || 也是中缀表达式 求值时短路
+	p.registerInfix(token.OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_AND, p.parseInfixExpression)
+	p.registerInfix(token.BIT_OR, p.parseInfixExpression)
+	p.registerInfix(token.BIT_XOR, p.parseInfixExpression)
+	p.registerInfix(token.SHL, p.parseInfixExpression)
+	p.registerInfix(token.SHR, p.parseInfixExpression)
+	p.registerInfix(token.ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PLUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.MINUS_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.ASTERISK_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.SLASH_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.PERCENT_ASSIGN, p.parseAssignExpression)
+	p.registerInfix(token.POWER_ASSIGN, p.parseAssignExpression)
 	p.registerInfix(token.LPAREN, p.parseCallExpression)    // "(" 解析函数
 	p.registerInfix(token.LBRACKET, p.parseIndexExpression) // [ 解析函数
 	// 读取两个词法单元 设置 curToken peekToken
@@ -95,6 +150,37 @@ func New(l *lexer.Lexer) *Parser {
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+	p.addLexerErrors()
+	switch p.curToken.Type {
+	case token.LBRACE:
+		p.braceDepth++
+	case token.RBRACE:
+		if p.braceDepth > 0 {
+			p.braceDepth--
+		}
+	}
+}
+
+// 新的词法错误转换为诊断信息 出错的词法单元仍然可以正常解析 不进入错误恢复
+func (p *Parser) addLexerErrors() {
+	errs := p.l.Errors()
+	for _, e := range errs[p.lexerErrors:] {
+		code, actual := ErrInvalidEscape, token.TokenType(token.STRING)
+		switch e.Kind {
+		case lexer.UnterminatedString:
+			code = ErrUnterminatedString
+		case lexer.UnterminatedComment:
+			code, actual = ErrUnterminatedComment, token.COMMENT
+		}
+		p.diagnostics = append(p.diagnostics, Diagnostic{
+			Severity: SeverityError,
+			Code:     code,
+			Span:     Span{Start: e.Start, End: e.End},
+			Actual:   actual,
+			Message:  e.Message,
+		})
+	}
+	p.lexerErrors = len(errs)
 }
 
 // 开始语法解析 生成ast
@@ -102,26 +188,140 @@ func (p *Parser) ParseProgram() *ast.Program {
 	program := &ast.Program{}
 	program.Statements = []ast.Statement{}
 	for !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement() // 去处理语句
+		stmt := p.parseStatementWithRecovery(0) // 去处理语句
 		if stmt != nil {
+			p.checkLoopControl(stmt, outsideLoop, true)
 			program.Statements = append(program.Statements, stmt) // 追加生成的语句
 		}
 		p.nextToken()
 	}
+	program.Comments = p.l.Comments()
 	return program
 }
 
+// 解析语句 出错时用占位语句代替 并跳过出错语句剩余的词法单元 继续解析后面的语句
+// blockDepth 是所在块的 { 的深度 顶层为0
+func (p *Parser) parseStatementWithRecovery(blockDepth int) ast.Statement {
+	start := p.curToken
+	stmt := p.parseStatement()
+	if p.panicking {
+		p.synchronize(blockDepth)
+		return &ast.BadStatement{Token: start}
+	}
+	return stmt
+}
+
+// 错误恢复 跳到出错语句的最后一个词法单元 同步点是:
+// 同层的 ; 下一个词法单元是 let return 或者是所在块的 }
+func (p *Parser) synchronize(blockDepth int) {
+	p.panicking = false
+	nesting := 0 // 出错之后遇到的括号的嵌套层数
+	for !p.curTokenIs(token.EOF) {
+		if p.curTokenIs(token.RBRACE) && p.braceDepth < blockDepth {
+			return // 出错的就是所在块的 } 留给块去结束
+		}
+		switch p.curToken.Type {
+		case token.LPAREN, token.LBRACKET, token.LBRACE:
+			nesting++
+		case token.RPAREN, token.RBRACKET, token.RBRACE:
+			if nesting > 0 {
+				nesting--
+			}
+		}
+		if nesting == 0 {
+			if p.curTokenIs(token.SEMICOLON) {
+				return
+			}
+			switch p.peekToken.Type {
+			case token.LET, token.RETURN, token.WHILE, token.FOR, token.THROW, token.EOF:
+				return
+			case token.RBRACE:
+				if p.braceDepth == blockDepth {
+					return // 下一个词法单元是所在块的 }
+				}
+			}
+		}
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.LET:
 		return p.parseLetStatement() // 处理let语句
 	case token.RETURN:
 		return p.parseReturnStatement() // 处理return
+	case token.THROW:
+		return p.parseThrowStatement()
+	case token.WHILE:
+		return p.parseWhileStatement()
+	case token.FOR:
+		return p.parseForStatement()
+	case token.BREAK:
+		stmt := &ast.BreakStatement{Token: p.curToken}
+		p.skipOptionalSemicolon()
+		return stmt
+	case token.CONTINUE:
+		stmt := &ast.ContinueStatement{Token: p.curToken}
+		p.skipOptionalSemicolon()
+		return stmt
 	default: // 不是语句 那就开始解析表达式了
 		return p.parseExpressionStatement()
 	}
 }
 
+// 解析while循环 while (条件) { 循环体 }
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Condition = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
+	p.skipOptionalSemicolon()
+	return stmt
+}
+
+// 解析for-in循环 for (变量 in 表达式) { 循环体 }
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+	if !p.expectPeek(token.LPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.IDENT) {
+		return nil
+	}
+	stmt.Variable = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	if !p.expectPeek(token.IN) {
+		return nil
+	}
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(LOWEST)
+	if !p.expectPeek(token.RPAREN) {
+		return nil
+	}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	stmt.Body = p.parseBlockStatement()
+	p.skipOptionalSemicolon()
+	return stmt
+}
+
+// 语句后面的分号可有可无
+func (p *Parser) skipOptionalSemicolon() {
+	if p.peekTokenIs(token.SEMICOLON) {
+		p.nextToken()
+	}
+}
+
 // parseExpressionStatement 解析表达式语句
 func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 	stmt := &ast.ExpressionStatement{Token: p.curToken}
@@ -166,6 +366,9 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 	}
 	p.nextToken() // = 跳过
 	stmt.Value = p.parseExpression(LOWEST)
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value // 记录函数绑定的名称
+	}
 	if p.peekTokenIs(token.SEMICOLON) {
 		p.nextToken()
 	}
@@ -182,6 +385,15 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+// 解析throw语句 throw 表达式;
+func (p *Parser) parseThrowStatement() *ast.ThrowStatement {
+	stmt := &ast.ThrowStatement{Token: p.curToken}
+	p.nextToken()
+	stmt.Value = p.parseExpression(LOWEST)
+	p.skipOptionalSemicolon()
+	return stmt
+}
+
 // 当前的tokenTyoe是否是想要的
 func (p *Parser) curTokenIs(t token.TokenType) bool {
 	return p.curToken.Type == t
@@ -205,15 +417,40 @@ func (p *Parser) expectPeek(t token.TokenType) bool {
 	}
 }
 
-// 查看错误
+// 查看错误 兼容旧的字符串形式 file:line:col: message
 func (p *Parser) Errors() []string {
-	return p.errors
+	errors := make([]string, len(p.diagnostics))
+	for i, d := range p.diagnostics {
+		errors[i] = d.String()
+	}
+	return errors
+}
+
+// 结构化的错误信息 按发现的先后顺序排列
+func (p *Parser) Diagnostics() []Diagnostic {
+	return p.diagnostics
 }
 
 // 添加错误
 func (p *Parser) peekError(t token.TokenType) {
 	msg := fmt.Sprintf("expected next token to be %s, got %s instead.", t, p.peekToken.Type)
-	p.errors = append(p.errors, msg)
+	p.addError(ErrUnexpectedToken, p.peekToken, t, msg)
+}
+
+// 添加错误 tok是出错的词法单元 expected是期望的词法单元类型 没有时为空
+func (p *Parser) addError(code string, tok token.Token, expected token.TokenType, msg string) {
+	if p.panicking {
+		return // 同一条语句只记录第一个错误
+	}
+	p.panicking = true
+	p.diagnostics = append(p.diagnostics, Diagnostic{
+		Severity: SeverityError,
+		Code:     code,
+		Span:     tokenSpan(tok),
+		Expected: expected,
+		Actual:   tok.Type,
+		Message:  msg,
+	})
 }
 
 // registerPrefix 工具方法 注册解析函数
@@ -238,18 +475,104 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	// 字面量是字符串形式的 "10" => 转换为 10
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		msg := fmt.Sprintf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, msg)
+		if errors.Is(err, strconv.ErrRange) { // 超出int64范围的是大整数
+			if bigValue, ok := new(big.Int).SetString(p.curToken.Literal, 0); ok {
+				return &ast.BigIntLiteral{Token: p.curToken, Value: bigValue}
+			}
+		}
+		p.numberLiteralError(ErrInvalidInteger, "integer", false)
+		return nil
+	}
+	lit.Value = value
+	return lit
+}
+
+// 解析为浮点数字面量
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	lit := &ast.FloatLiteral{Token: p.curToken}
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		if errors.Is(err, strconv.ErrRange) { // 超出范围 比如 1e400
+			msg := fmt.Sprintf("float literal %s out of range", p.curToken.Literal)
+			p.addError(ErrInvalidFloat, p.curToken, "", msg)
+			return nil
+		}
+		p.numberLiteralError(ErrInvalidFloat, "float", true)
 		return nil
 	}
 	lit.Value = value
 	return lit
 }
 
+// 数字字面量不合法 错误指向第一个不合法的字符
+func (p *Parser) numberLiteralError(code, kind string, isFloat bool) {
+	tok := p.curToken
+	index, msg := invalidDigit(tok.Literal, isFloat)
+	if index < 0 { // 找不到具体的字符 指向整个字面量
+		p.addError(code, tok, "", fmt.Sprintf("could not parse %q as %s", tok.Literal, kind))
+		return
+	}
+	at := tok
+	at.Pos.Offset += index
+	at.Pos.Column += index
+	at.Literal = ""
+	if index < len(tok.Literal) {
+		at.Literal = tok.Literal[index : index+1]
+	}
+	p.addError(code, at, "", msg)
+}
+
+// 找到数字字面量中第一个不合法的字符 返回它的下标和错误信息 找不到时返回-1
+func invalidDigit(literal string, isFloat bool) (int, string) {
+	digits, base, start := "0123456789", "decimal", 0
+	if len(literal) > 1 && literal[0] == '0' {
+		switch literal[1] {
+		case 'x', 'X':
+			digits, base, start = "0123456789abcdefABCDEF", "hexadecimal", 2
+		case 'o', 'O':
+			digits, base, start = "01234567", "octal", 2
+		case 'b', 'B':
+			digits, base, start = "01", "binary", 2
+		default:
+			if !isFloat { // 和strconv一致 0开头的整数是八进制 017
+				digits, base, start = "01234567", "octal", 1
+			}
+		}
+	}
+	if start == 2 && len(literal) == 2 {
+		return 2, fmt.Sprintf("%s literal has no digits", base)
+	}
+	isDigit := func(i int) bool {
+		return i >= 0 && i < len(literal) && strings.IndexByte(digits, literal[i]) >= 0
+	}
+	for i := start; i < len(literal); i++ {
+		switch ch := literal[i]; {
+		case ch == '_':
+			// 分隔符两侧必须是数字 0x_1F 这样紧跟在前缀后面也可以
+			if !(isDigit(i-1) || i == 2 && start == 2) || !isDigit(i+1) {
+				return i, "'_' must separate successive digits"
+			}
+		case isFloat && strings.IndexByte(".eE+-", ch) >= 0:
+		case !isDigit(i):
+			return i, fmt.Sprintf("invalid digit %q in %s literal", ch, base)
+		}
+	}
+	if i := strings.IndexAny(literal, "eE"); isFloat && i >= 0 {
+		i++
+		if i < len(literal) && (literal[i] == '+' || literal[i] == '-') {
+			i++
+		}
+		if i == len(literal) { // 1e 1e+
+			return i, "exponent has no digits"
+		}
+	}
+	return -1, ""
+}
+
 // 没有前缀表达式对应的解析函数 错误收集
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
 	msg := fmt.Sprintf("no prefix parse function for %s found.", t)
-	p.errors = append(p.errors, msg)
+	p.addError(ErrNoPrefixParseFn, p.curToken, "", msg)
 }
 
 // - ! 对应的前缀表达式解析函数
@@ -287,11 +610,34 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 		Left:     left,
 	}
 	precedence := p.curPrecedence()
+	if precedence == POWER { // 右结合 2 ** 3 ** 2 是 2 ** (3 ** 2)
+		precedence--
+	}
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence) // 解析右半部分
 	return expression
 }
 
+// 解析赋值表达式 左侧只能是标识符或者索引表达式
+// 赋值是右结合的 a = b = 1 是 a = (b = 1) 右侧用更低的优先级解析
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	expression := &ast.AssignExpression{
+		Token:    p.curToken,
+		Target:   target,
+		Operator: p.curToken.Literal,
+	}
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	case nil: // 左侧已经出错了
+	default:
+		msg := fmt.Sprintf("invalid assignment target %s", target.String())
+		p.addError(ErrInvalidAssignment, p.curToken, "", msg)
+	}
+	p.nextToken()
+	expression.Value = p.parseExpression(ASSIGN - 1)
+	return expression
+}
+
 // 解析布尔字面量
 func (p *Parser) parseBoolean() ast.Expression {
 	return &ast.Boolean{
@@ -339,17 +685,60 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	return expression
 }
 
+// 解析try表达式 try { } catch (e) { } finally { } catch和finally至少要有一个
+func (p *Parser) parseTryExpression() ast.Expression {
+	expression := &ast.TryExpression{Token: p.curToken}
+	if !p.expectPeek(token.LBRACE) {
+		return nil
+	}
+	expression.Block = p.parseBlockStatement()
+	if !p.peekTokenIs(token.CATCH) && !p.peekTokenIs(token.FINALLY) {
+		msg := fmt.Sprintf("expected next token to be CATCH or FINALLY, got %s instead.", p.peekToken.Type)
+		p.addError(ErrUnexpectedToken, p.peekToken, token.CATCH, msg)
+		return nil
+	}
+	if p.peekTokenIs(token.CATCH) {
+		p.nextToken()
+		if !p.expectPeek(token.LPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.IDENT) {
+			return nil
+		}
+		expression.Parameter = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+		if !p.expectPeek(token.RPAREN) {
+			return nil
+		}
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Catch = p.parseBlockStatement()
+	}
+	if p.peekTokenIs(token.FINALLY) {
+		p.nextToken()
+		if !p.expectPeek(token.LBRACE) {
+			return nil
+		}
+		expression.Finally = p.parseBlockStatement()
+	}
+	return expression
+}
+
 // 解析块级语句
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
-	block := &ast.BlockStatement{}
+	block := &ast.BlockStatement{Token: p.curToken}
 	block.Statements = []ast.Statement{}
-	p.nextToken() // 跳过 {
+	depth := p.braceDepth // 包括当前块的 {
+	p.nextToken()         // 跳过 {
 	// 不是 } 不是结束符
 	for !p.curTokenIs(token.RBRACE) && !p.curTokenIs(token.EOF) {
-		stmt := p.parseStatement()
+		stmt := p.parseStatementWithRecovery(depth)
 		if stmt != nil {
 			block.Statements = append(block.Statements, stmt)
 		}
+		if p.curTokenIs(token.RBRACE) && p.braceDepth < depth {
+			break // 错误恢复停在了当前块的 } 上
+		}
 		p.nextToken()
 	}
 	return block
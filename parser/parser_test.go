@@ -84,7 +84,7 @@ func TestParsingHashLiteralsStringKeys(t *testing.T) {
 			t.Errorf("key is not ast.StringLiteral. got=%T", key)
 		}
 
-		expectedValue := expected[literal.String()]
+		expectedValue := expected[literal.Value]
 
 		testIntegerLiteral(t, value, expectedValue)
 	}
@@ -146,9 +146,9 @@ func TestParsingHashLiteralsWithExpressions(t *testing.T) {
 			continue
 		}
 
-		testFunc, ok := tests[literal.String()]
+		testFunc, ok := tests[literal.Value]
 		if !ok {
-			t.Errorf("No test function for key %q found", literal.String())
+			t.Errorf("No test function for key %q found", literal.Value)
 			continue
 		}
 
@@ -555,6 +555,123 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 			"add(a * b[2], b[1], 2 * [1, 2][1])",
 			"add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))",
 		},
+		// in 和比较运算的优先级相同
+		{
+			"a in b == c < d",
+			"((a in b) == (c < d))",
+		},
+		{
+			"a + b in c",
+			"((a + b) in c)",
+		},
+		{
+			"!a in b",
+			"((!a) in b)",
+		},
+		// % 和 * / 的优先级相同 ** 更高并且是右结合的 但低于前缀运算符
+		{
+			"a + b % c * d",
+			"(a + ((b % c) * d))",
+		},
+		{
+			"2 ** 3 ** 2",
+			"(2 ** (3 ** 2))",
+		},
+		{
+			"a * b ** c",
+			"(a * (b ** c))",
+		},
+		{
+			"a ** b * c",
+			"((a ** b) * c)",
+		},
+		{
+			"-2 ** 2",
+			"((-2) ** 2)",
+		},
+		{
+			"2 ** -1",
+			"(2 ** (-1))",
+		},
+		{
+			"a ** b[0] ** f(c)",
+			"(a ** ((b[0]) ** f(c)))",
+		},
+		{
+			"x %= y ** 2",
+			"(x %= (y ** 2))",
+		},
+		// 位运算符的优先级和C语言一致 | < ^ < & < == < < > < << >> < + -
+		{
+			"a | b ^ c & d",
+			"(a | (b ^ (c & d)))",
+		},
+		{
+			"a & b == c",
+			"(a & (b == c))",
+		},
+		{
+			"a == b < c << d",
+			"(a == (b < (c << d)))",
+		},
+		{
+			"1 << 2 + 3",
+			"(1 << (2 + 3))",
+		},
+		{
+			"a >> 1 << 2",
+			"((a >> 1) << 2)",
+		},
+		{
+			"~a & -b",
+			"((~a) & (-b))",
+		},
+		{
+			"a | b | c",
+			"((a | b) | c)",
+		},
+		// || 的优先级最低 然后是 && 都低于位运算
+		{
+			"a || b && c",
+			"(a || (b && c))",
+		},
+		{
+			"a && b || c && d",
+			"((a && b) || (c && d))",
+		},
+		{
+			"a <= b && c >= d",
+			"((a <= b) && (c >= d))",
+		},
+		{
+			"a | b && c",
+			"((a | b) && c)",
+		},
+		{
+			"!a || b",
+			"((!a) || b)",
+		},
+		// 赋值的优先级最低 并且是右结合的
+		{
+			"a = b || c",
+			"(a = (b || c))",
+		},
+		{
+			"a = b = c + 1",
+			"(a = (b = (c + 1)))",
+		},
+		{
+			"a += b * 2",
+			"(a += (b * 2))",
+		},
+		{
+			"arr[i + 1] -= f(x)",
+			"((arr[(i + 1)]) -= f(x))",
+		},
+		{
+			"h[\"k\"] *= 2",
+			"((h[\"k\"]) *= 2)",
+		},
 	}
 
 	for _, tt := range tests {
@@ -618,6 +735,9 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"5 - 5;", 5, "-", 5},
 		{"5 * 5;", 5, "*", 5},
 		{"5 / 5;", 5, "/", 5},
+		{"5 % 5;", 5, "%", 5},
+		{"5 ** 5;", 5, "**", 5},
+		{"5 in 5;", 5, "in", 5},
 		{"5 > 5;", 5, ">", 5},
 		{"5 < 5;", 5, "<", 5},
 		{"5 == 5;", 5, "==", 5},
@@ -625,6 +745,15 @@ func TestParsingInfixExpressions(t *testing.T) {
 		{"true == true", true, "==", true},
 		{"true != false", true, "!=", false},
 		{"false == false", false, "==", false},
+		{"5 & 5;", 5, "&", 5},
+		{"5 | 5;", 5, "|", 5},
+		{"5 ^ 5;", 5, "^", 5},
+		{"5 << 5;", 5, "<<", 5},
+		{"5 >> 5;", 5, ">>", 5},
+		{"5 <= 5;", 5, "<=", 5},
+		{"5 >= 5;", 5, ">=", 5},
+		{"true && false", true, "&&", false},
+		{"true || false", true, "||", false},
 	}
 
 	for _, tt := range infixTests {
@@ -661,6 +790,7 @@ func TestParsingPrefixExpressions(t *testing.T) {
 		{"-15;", "-", 15},
 		{"!true;", "!", true},
 		{"!false;", "!", false},
+		{"~5;", "~", 5},
 	}
 
 	for _, tt := range prefixTests {
@@ -725,6 +855,446 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected float64
+	}{
+		{"3.14;", 3.14},
+		{"1e-9;", 1e-9},
+		{"2.5E3;", 2500},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.FloatLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.FloatLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("literal.Value not %g. got=%g", tt.expected, literal.Value)
+		}
+	}
+
+	p := New(lexer.New("1e400"))
+	p.ParseProgram()
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 1 || diagnostics[0].Code != ErrInvalidFloat {
+		t.Fatalf("expected one %s diagnostic, got %+v", ErrInvalidFloat, diagnostics)
+	}
+	if diagnostics[0].Message != `float literal 1e400 out of range` {
+		t.Errorf("wrong message %q", diagnostics[0].Message)
+	}
+}
+
+// 打印的字符串字面量重新解析后得到相同的值
+func TestStringLiteralRoundTrip(t *testing.T) {
+	inputs := []string{
+		`"a\nb"`,
+		`"quote \" and \\"`,
+		"`raw\n\\ \"x\"`",
+		`"\u{1F600}\u{7}\0"`,
+	}
+
+	for _, input := range inputs {
+		p := New(lexer.New(input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		first := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.StringLiteral)
+
+		p = New(lexer.New(first.String()))
+		program = p.ParseProgram()
+		checkParserErrors(t, p)
+		second := program.Statements[0].(*ast.ExpressionStatement).Expression.(*ast.StringLiteral)
+		if first.Value != second.Value {
+			t.Errorf("%s: value changed after round trip through %s. want=%q, got=%q",
+				input, first.String(), first.Value, second.Value)
+		}
+	}
+}
+
+func TestLexerErrorDiagnostics(t *testing.T) {
+	input := "let a = \"x\\qy\";\nlet b = \"open"
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 2 {
+		t.Fatalf("expected 2 diagnostics, got %v", p.Errors())
+	}
+	if d := diagnostics[0]; d.Code != ErrInvalidEscape || d.Span.Start.Line != 1 || d.Span.Start.Column != 11 ||
+		d.Message != `unknown escape sequence \q` {
+		t.Errorf("wrong escape diagnostic. got=%+v", d)
+	}
+	if d := diagnostics[1]; d.Code != ErrUnterminatedString || d.Span.Start.Line != 2 || d.Span.Start.Column != 9 {
+		t.Errorf("wrong unterminated diagnostic. got=%+v", d)
+	}
+	// 词法错误不影响解析 两条let语句都在
+	if len(program.Statements) != 2 {
+		t.Errorf("expected 2 statements, got %d", len(program.Statements))
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// 加法
+let add = fn(a, b) { a /* 左 */ + b };
+/* 未结束的注释`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("expected 1 statement, got %d", len(program.Statements))
+	}
+	if got := program.String(); got != "let add = fn(a, b)(a + b);" {
+		t.Errorf("program.String() wrong. got=%q", got)
+	}
+	if len(program.Comments) != 3 || program.Comments[0].Literal != "// 加法" ||
+		program.Comments[1].Literal != "/* 左 */" {
+		t.Errorf("program.Comments wrong. got=%+v", program.Comments)
+	}
+
+	diagnostics := p.Diagnostics()
+	if len(diagnostics) != 1 {
+		t.Fatalf("expected 1 diagnostic, got %v", p.Errors())
+	}
+	if d := diagnostics[0]; d.Code != ErrUnterminatedComment || d.Span.Start.Line != 3 || d.Span.Start.Column != 1 ||
+		d.Actual != "COMMENT" || d.Message != "unterminated block comment" {
+		t.Errorf("wrong diagnostic. got=%+v", d)
+	}
+}
+
+func TestAssignExpression(t *testing.T) {
+	tests := []struct {
+		input            string
+		expectedTarget   string
+		expectedOperator string
+		expectedValue    string
+	}{
+		{"x = 5;", "x", "=", "5"},
+		{"x += y;", "x", "+=", "y"},
+		{"x -= 1", "x", "-=", "1"},
+		{"x *= 2", "x", "*=", "2"},
+		{"x /= 2", "x", "/=", "2"},
+		{"x %= 2", "x", "%=", "2"},
+		{"x **= 2", "x", "**=", "2"},
+		{"arr[0] = true", "(arr[0])", "=", "true"},
+		{"h[\"a\"] += 1", "(h[\"a\"])", "+=", "1"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		if len(program.Statements) != 1 {
+			t.Fatalf("%s: expected 1 statement, got %d", tt.input, len(program.Statements))
+		}
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		exp, ok := stmt.Expression.(*ast.AssignExpression)
+		if !ok {
+			t.Fatalf("%s: expression is not *ast.AssignExpression. got=%T", tt.input, stmt.Expression)
+		}
+		if exp.Target.String() != tt.expectedTarget || exp.Operator != tt.expectedOperator ||
+			exp.Value.String() != tt.expectedValue {
+			t.Errorf("%s: wrong assignment. got target=%s operator=%s value=%s",
+				tt.input, exp.Target, exp.Operator, exp.Value)
+		}
+	}
+}
+
+func TestInvalidAssignmentTarget(t *testing.T) {
+	tests := []struct {
+		input           string
+		expectedColumn  int
+		expectedMessage string
+	}{
+		{"1 = 2", 3, "invalid assignment target 1"},
+		{"a + b = c", 7, "invalid assignment target (a + b)"},
+		{"f() += 1", 5, "invalid assignment target f()"},
+		{"a == b = c", 8, "invalid assignment target (a == b)"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Errorf("%s: expected 1 diagnostic, got %v", tt.input, p.Errors())
+			continue
+		}
+		if d := diagnostics[0]; d.Code != ErrInvalidAssignment || d.Span.Start.Column != tt.expectedColumn ||
+			d.Message != tt.expectedMessage {
+			t.Errorf("%s: wrong diagnostic. got=%+v", tt.input, d)
+		}
+	}
+}
+
+func TestWhileStatement(t *testing.T) {
+	input := `while (x < 10) { x += 1; if (x == 5) { break; } else { continue } }`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 1 {
+		t.Fatalf("expected 1 statement, got %d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.WhileStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.WhileStatement. got=%T", program.Statements[0])
+	}
+	if !testInfixExpression(t, stmt.Condition, "x", "<", 10) {
+		return
+	}
+	if len(stmt.Body.Statements) != 2 {
+		t.Fatalf("expected 2 statements in body, got %d", len(stmt.Body.Statements))
+	}
+	expected := "while(x < 10) (x += 1)if(x == 5) break;else continue;"
+	if program.String() != expected {
+		t.Errorf("program.String() wrong. expected=%q, got=%q", expected, program.String())
+	}
+}
+
+func TestForStatement(t *testing.T) {
+	input := `for (item in [1, 2]) { puts(item); }; item`
+	p := New(lexer.New(input))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("expected 2 statements, got %d", len(program.Statements))
+	}
+	stmt, ok := program.Statements[0].(*ast.ForStatement)
+	if !ok {
+		t.Fatalf("statement is not *ast.ForStatement. got=%T", program.Statements[0])
+	}
+	if !testIdentifier(t, stmt.Variable, "item") {
+		return
+	}
+	if stmt.Iterable.String() != "[1, 2]" {
+		t.Errorf("wrong iterable. got=%s", stmt.Iterable)
+	}
+	if stmt.Body.String() != "puts(item)" {
+		t.Errorf("wrong body. got=%s", stmt.Body)
+	}
+}
+
+func TestThrowStatement(t *testing.T) {
+	p := New(lexer.New(`throw "bad" + x; throw e`))
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	expected := []string{`throw ("bad" + x);`, `throw e;`}
+	if len(program.Statements) != len(expected) {
+		t.Fatalf("expected %d statements, got %d", len(expected), len(program.Statements))
+	}
+	for i, s := range program.Statements {
+		if _, ok := s.(*ast.ThrowStatement); !ok {
+			t.Fatalf("statement is not *ast.ThrowStatement. got=%T", s)
+		}
+		if s.String() != expected[i] {
+			t.Errorf("wrong statement. want=%q, got=%q", expected[i], s.String())
+		}
+	}
+}
+
+func TestTryExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { f(1) } catch (e) { e }", "try f(1) catch (e) e"},
+		{"try { f() } finally { g() }", "try f() finally g()"},
+		{"try { f() } catch (err) { 0 } finally { g() }", "try f() catch (err) 0 finally g()"},
+		{"let x = try { 1 } catch (e) { 2 };", "let x = try 1 catch (e) 2;"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+		if program.String() != tt.expected {
+			t.Errorf("expected=%q, got=%q", tt.expected, program.String())
+		}
+	}
+
+	p := New(lexer.New("try { f() } catch (e) { e }"))
+	stmt := p.ParseProgram().Statements[0].(*ast.ExpressionStatement)
+	te, ok := stmt.Expression.(*ast.TryExpression)
+	if !ok {
+		t.Fatalf("expression is not *ast.TryExpression. got=%T", stmt.Expression)
+	}
+	if !testIdentifier(t, te.Parameter, "e") {
+		return
+	}
+	if te.Finally != nil {
+		t.Errorf("unexpected finally block %s", te.Finally)
+	}
+}
+
+func TestTryExpressionErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"try { f() }", "1:12: expected next token to be CATCH or FINALLY, got EOF instead."},
+		{"try { f() } catch { 1 }", "1:19: expected next token to be (, got { instead."},
+		{"try { f() } catch (1) { 1 }", "1:20: expected next token to be IDENT, got INT instead."},
+		{"try f() catch (e) { 1 }", "1:5: expected next token to be {, got IDENT instead."},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		errors := p.Errors()
+		if len(errors) == 0 || errors[0] != tt.expected {
+			t.Errorf("%s: expected first error %q, got %v", tt.input, tt.expected, errors)
+		}
+	}
+}
+
+func TestLoopControlErrors(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []string // 诊断信息 格式为 行:列 信息
+	}{
+		{"break;", []string{"1:1 break outside loop"}},
+		{"if (true) { continue }", []string{"1:13 continue outside loop"}},
+		{"while (true) { let f = fn() { break; }; }", []string{"1:31 break outside loop"}},
+		{"while (true) { 1 + if (true) { break } }", []string{"1:32 break cannot be used inside an expression"}},
+		{"for (x in a) { let y = if (x) { continue }; }", []string{"1:33 continue cannot be used inside an expression"}},
+		{"while (true) { puts(if (true) { break }) }", []string{"1:33 break cannot be used inside an expression"}},
+		// 嵌套的循环 作为语句的if中都可以使用
+		{"while (a) { for (x in b) { if (x) { if (y) { break } else { continue } } }; break }", nil},
+		// 错误不影响后面的语句
+		{"break; continue; while (true) { break }", []string{"1:1 break outside loop", "1:8 continue outside loop"}},
+		// 作为语句的try中可以使用 finally中不能跳出循环
+		{"while (a) { try { break } catch (e) { continue } }", nil},
+		{"while (a) { try { f() } finally { break } }", []string{"1:35 break cannot be used inside finally"}},
+		{"while (a) { try { f() } finally { while (b) { break } } }", nil},
+		{"while (a) { let x = try { break } catch (e) { 1 }; }", []string{"1:27 break cannot be used inside an expression"}},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != len(tt.expected) {
+			t.Errorf("%s: expected %d diagnostics, got %v", tt.input, len(tt.expected), p.Errors())
+			continue
+		}
+		for i, d := range diagnostics {
+			got := fmt.Sprintf("%d:%d %s", d.Span.Start.Line, d.Span.Start.Column, d.Message)
+			if got != tt.expected[i] || d.Code != ErrMisplacedLoopControl {
+				t.Errorf("%s: diagnostics[%d] - expected %q, got %q (%s)", tt.input, i, tt.expected[i], got, d.Code)
+			}
+		}
+	}
+}
+
+func TestIntegerLiteralBases(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int64
+	}{
+		{"0x1F", 31},
+		{"0XFF", 255},
+		{"0o17", 15},
+		{"0b1010", 10},
+		{"1_000_000", 1000000},
+		{"0x_dead_beef", 0xdeadbeef},
+		{"0x7fffffffffffffff", 9223372036854775807},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.IntegerLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.IntegerLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value != tt.expected {
+			t.Errorf("%q: literal.Value not %d. got=%d", tt.input, tt.expected, literal.Value)
+		}
+	}
+}
+
+func TestBigIntLiterals(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"9223372036854775808", "9223372036854775808"},
+		{"0x8000000000000000", "9223372036854775808"},
+		{"0xffff_ffff_ffff_ffff_ffff", "1208925819614629174706175"},
+		{"0b1_0000000000000000000000000000000000000000000000000000000000000000", "18446744073709551616"},
+		{"100_000_000_000_000_000_000", "100000000000000000000"},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		program := p.ParseProgram()
+		checkParserErrors(t, p)
+
+		stmt := program.Statements[0].(*ast.ExpressionStatement)
+		literal, ok := stmt.Expression.(*ast.BigIntLiteral)
+		if !ok {
+			t.Fatalf("exp not *ast.BigIntLiteral. got=%T", stmt.Expression)
+		}
+		if literal.Value.String() != tt.expected {
+			t.Errorf("%q: literal.Value not %s. got=%s", tt.input, tt.expected, literal.Value)
+		}
+		if literal.String() != tt.input {
+			t.Errorf("%q: literal.String() wrong. got=%q", tt.input, literal.String())
+		}
+	}
+}
+
+func TestInvalidNumberLiterals(t *testing.T) {
+	tests := []struct {
+		input          string
+		expectedCode   string
+		expectedColumn int
+		expectedMsg    string
+	}{
+		{"x + 0b102", ErrInvalidInteger, 9, `invalid digit '2' in binary literal`},
+		{"0o8", ErrInvalidInteger, 3, `invalid digit '8' in octal literal`},
+		{"0xfg", ErrInvalidInteger, 4, `invalid digit 'g' in hexadecimal literal`},
+		{"019", ErrInvalidInteger, 3, `invalid digit '9' in octal literal`},
+		{"0x", ErrInvalidInteger, 3, `hexadecimal literal has no digits`},
+		{"1__000", ErrInvalidInteger, 2, `'_' must separate successive digits`},
+		{"1000_", ErrInvalidInteger, 5, `'_' must separate successive digits`},
+		{"1_.5", ErrInvalidFloat, 2, `'_' must separate successive digits`},
+		{"1e", ErrInvalidFloat, 3, `exponent has no digits`},
+		{"x * 2.5E-", ErrInvalidFloat, 10, `exponent has no digits`},
+		{"99999999999999999999_", ErrInvalidInteger, 21, `'_' must separate successive digits`},
+	}
+
+	for _, tt := range tests {
+		p := New(lexer.New(tt.input))
+		p.ParseProgram()
+		diagnostics := p.Diagnostics()
+		if len(diagnostics) != 1 {
+			t.Errorf("%q: expected 1 diagnostic, got %v", tt.input, p.Errors())
+			continue
+		}
+		d := diagnostics[0]
+		if d.Code != tt.expectedCode || d.Span.Start.Column != tt.expectedColumn || d.Message != tt.expectedMsg {
+			t.Errorf("%q: expected %s at column %d %q, got %s at column %d %q",
+				tt.input, tt.expectedCode, tt.expectedColumn, tt.expectedMsg, d.Code, d.Span.Start.Column, d.Message)
+		}
+	}
+}
+
 func TestIdentifierExpression(t *testing.T) {
 	input := "foo;"
 	l := lexer.New(input)
@@ -946,3 +1516,120 @@ func testBooleanLiteral(t *testing.T, exp ast.Expression, value bool) bool {
 
 	return true
 }
+
+func TestParserErrorPositions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x 5;", "1:7: expected next token to be =, got INT instead."},
+		{"let x = 1;\nadd(1, 2;", "2:9: expected next token to be ), got ; instead."},
+		{"let a = [1,\n\t];", "2:2: no prefix parse function for ] found."},
+	}
+
+	for _, tt := range tests {
+		l := lexer.New(tt.input)
+		p := New(l)
+		p.ParseProgram()
+
+		errors := p.Errors()
+		if len(errors) == 0 {
+			t.Errorf("expected parser errors for %q", tt.input)
+			continue
+		}
+		if errors[0] != tt.expected {
+			t.Errorf("wrong error. want=%q, got=%q", tt.expected, errors[0])
+		}
+	}
+}
+
+func TestNodePositions(t *testing.T) {
+	input := "let add = fn(a, b) {\n  a + b\n};"
+
+	l := lexer.NewWithFilename("add.mk", input)
+	p := New(l)
+	program := p.ParseProgram()
+	checkParserErrors(t, p)
+
+	let := program.Statements[0].(*ast.LetStatement)
+	fn := let.Value.(*ast.FunctionLiteral)
+	body := fn.Body.Statements[0].(*ast.ExpressionStatement)
+	infix := body.Expression.(*ast.InfixExpression)
+
+	tests := []struct {
+		node     ast.Node
+		expected string
+	}{
+		{program, "add.mk:1:1"},
+		{let.Name, "add.mk:1:5"},
+		{fn, "add.mk:1:11"},
+		{fn.Body, "add.mk:1:20"},
+		{infix, "add.mk:2:5"},
+		{infix.Right, "add.mk:2:7"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.node.Pos().String(); got != tt.expected {
+			t.Errorf("%s: wrong position. want=%q, got=%q", tt.node, tt.expected, got)
+		}
+	}
+}
+
+func TestErrorRecovery(t *testing.T) {
+	input := `let x 5;
+let add = fn(a, b) {
+  let c = a + ;
+  return c;
+};
+let y = add(1, 2;
+let h = {"a": };
+let ok = 10;
+let z = fn() { let = 3; z }
+`
+	l := lexer.New(input)
+	p := New(l)
+	program := p.ParseProgram()
+
+	expectedErrors := []string{
+		"1:7: expected next token to be =, got INT instead.",
+		"3:15: no prefix parse function for ; found.",
+		"6:17: expected next token to be ), got ; instead.",
+		"7:15: no prefix parse function for } found.",
+		"9:20: expected next token to be IDENT, got = instead.",
+	}
+	errors := p.Errors()
+	if len(errors) != len(expectedErrors) {
+		t.Fatalf("wrong number of errors. want=%d, got=%d (%q)", len(expectedErrors), len(errors), errors)
+	}
+	for i, expected := range expectedErrors {
+		if errors[i] != expected {
+			t.Errorf("errors[%d] wrong. want=%q, got=%q", i, expected, errors[i])
+		}
+	}
+
+	// 出错的语句被占位语句代替 其余语句正常解析
+	expectedStatements := []string{
+		"<bad statement>",
+		"let add = fn(a, b)<bad statement>return c;;",
+		"<bad statement>",
+		"<bad statement>",
+		"let ok = 10;",
+		"let z = fn()<bad statement>z;",
+	}
+	if len(program.Statements) != len(expectedStatements) {
+		t.Fatalf("wrong number of statements. want=%d, got=%d", len(expectedStatements), len(program.Statements))
+	}
+	for i, expected := range expectedStatements {
+		if program.Statements[i].String() != expected {
+			t.Errorf("statements[%d] wrong. want=%q, got=%q", i, expected, program.Statements[i].String())
+		}
+	}
+
+	bad, ok := program.Statements[2].(*ast.BadStatement)
+	if !ok {
+		t.Fatalf("statement is not ast.BadStatement. got=%T", program.Statements[2])
+	}
+	if bad.Pos().String() != "6:1" {
+		t.Errorf("bad statement has wrong position. got=%s", bad.Pos())
+	}
+}
@@ -0,0 +1,366 @@
+// 简单的终端行编辑器 不依赖cgo
+// 支持光标移动 删除 历史记录 输入不是终端时退化为逐行读取
+package readline
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"monkey/width"
+	"os"
+	"strings"
+)
+
+// 用户按下了Ctrl-C 放弃当前输入
+var ErrInterrupt = errors.New("interrupt")
+
+const MaxHistory = 1000 // 最多保留的历史记录条数
+
+// 控制字符
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyLF        = 10
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyCR        = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+type Editor struct {
+	reader      *bufio.Reader
+	out         io.Writer
+	fd          uintptr
+	terminal    bool     // 输入是否是终端 只有终端才能进入原始模式编辑
+	history     []string // 历史记录 最新的在最后
+	historyPath string   // 历史记录文件 为空时不保存
+	fileLines   int      // 历史记录文件中的条数 超过MaxHistory时重写文件
+}
+
+// in是终端时支持行编辑 否则逐行读取
+func New(in io.Reader, out io.Writer) *Editor {
+	e := &Editor{reader: bufio.NewReader(in), out: out}
+	if f, ok := in.(*os.File); ok && isTerminal(f.Fd()) {
+		e.fd = f.Fd()
+		e.terminal = true
+	}
+	return e
+}
+
+// 输入是否是终端
+func (e *Editor) IsTerminal() bool {
+	return e.terminal
+}
+
+// 显示提示符并读取一行 不包括换行符
+// 输入结束时返回io.EOF 按下Ctrl-C时返回ErrInterrupt
+func (e *Editor) Readline(prompt string) (string, error) {
+	if !e.terminal {
+		return e.readPlainLine(prompt)
+	}
+	state, err := makeRaw(e.fd)
+	if err != nil {
+		return e.readPlainLine(prompt)
+	}
+	defer restore(e.fd, state)
+	return e.edit(prompt)
+}
+
+// 不是终端 没有编辑功能
+func (e *Editor) readPlainLine(prompt string) (string, error) {
+	io.WriteString(e.out, prompt)
+	line, err := e.reader.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+	return strings.TrimRight(line, "\r\n"), nil
+}
+
+// 添加历史记录 忽略空行和与上一条相同的输入 设置了历史文件时同时追加到文件中
+// 一条记录可以有多行 比如REPL中多行输入的函数 在文件中占一行 换行符保存为\r
+// 文件中的记录超过MaxHistory条时 用最近的MaxHistory条重写文件
+func (e *Editor) AddHistory(line string) {
+	if strings.TrimSpace(line) == "" {
+		return
+	}
+	if len(e.history) > 0 && e.history[len(e.history)-1] == line {
+		return
+	}
+	e.history = append(e.history, line)
+	if len(e.history) > MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory:]
+	}
+	if e.historyPath == "" {
+		return
+	}
+	if e.fileLines >= MaxHistory {
+		e.saveHistory() // 历史记录保存失败不影响使用
+		return
+	}
+	f, err := os.OpenFile(e.historyPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return // 历史记录保存失败不影响使用
+	}
+	defer f.Close()
+	if _, err := fmt.Fprintln(f, encodeHistory(line)); err == nil {
+		e.fileLines++
+	}
+}
+
+// 用内存中的历史记录重写历史文件
+func (e *Editor) saveHistory() error {
+	var out strings.Builder
+	for _, line := range e.history {
+		out.WriteString(encodeHistory(line) + "\n")
+	}
+	if err := os.WriteFile(e.historyPath, []byte(out.String()), 0600); err != nil {
+		return err
+	}
+	e.fileLines = len(e.history)
+	return nil
+}
+
+// 读取历史记录文件 之后的输入也会追加到这个文件 文件不存在时不是错误
+func (e *Editor) LoadHistory(path string) error {
+	e.historyPath = path
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if line = strings.TrimRight(line, "\r"); line != "" {
+			e.history = append(e.history, strings.ReplaceAll(line, "\r", "\n"))
+		}
+	}
+	e.fileLines = len(e.history)
+	if len(e.history) > MaxHistory {
+		e.history = e.history[len(e.history)-MaxHistory:]
+		return e.saveHistory() // 之前保存的文件可能超过了限制
+	}
+	return nil
+}
+
+// 历史记录文件中的一行 输入中不会有\r 回车键结束输入
+func encodeHistory(line string) string {
+	return strings.ReplaceAll(line, "\n", "\r")
+}
+
+// 历史记录
+func (e *Editor) History() []string {
+	return e.history
+}
+
+// 编辑中的一行
+type line struct {
+	buf []rune
+	pos int // 光标位置 buf的下标
+}
+
+// 原始模式下逐个按键编辑
+func (e *Editor) edit(prompt string) (string, error) {
+	l := &line{}
+	historyIndex := len(e.history) // 等于len(history)时表示正在编辑的新输入
+	current := ""                  // 浏览历史记录之前正在编辑的输入
+	e.refresh(prompt, l)
+	for {
+		r, _, err := e.reader.ReadRune()
+		if err != nil {
+			if err == io.EOF && len(l.buf) > 0 {
+				io.WriteString(e.out, "\r\n")
+				return string(l.buf), nil
+			}
+			return "", err
+		}
+		switch r {
+		case keyCR, keyLF:
+			io.WriteString(e.out, "\r\n")
+			return string(l.buf), nil
+		case keyCtrlC:
+			io.WriteString(e.out, "^C\r\n")
+			return "", ErrInterrupt
+		case keyCtrlD:
+			if len(l.buf) == 0 {
+				io.WriteString(e.out, "\r\n")
+				return "", io.EOF
+			}
+			l.delete()
+		case keyBackspace, keyCtrlH:
+			l.backspace()
+		case keyCtrlA:
+			l.pos = 0
+		case keyCtrlE:
+			l.pos = len(l.buf)
+		case keyCtrlB:
+			l.left()
+		case keyCtrlF:
+			l.right()
+		case keyCtrlK:
+			l.buf = l.buf[:l.pos]
+		case keyCtrlU:
+			l.buf = l.buf[l.pos:]
+			l.pos = 0
+		case keyCtrlW:
+			l.deleteWord()
+		case keyCtrlL:
+			io.WriteString(e.out, "\x1b[H\x1b[2J") // 清屏
+		case keyCtrlP:
+			historyIndex, current = e.browse(l, historyIndex, historyIndex-1, current)
+		case keyCtrlN:
+			historyIndex, current = e.browse(l, historyIndex, historyIndex+1, current)
+		case keyTab:
+			l.insert(' ')
+			l.insert(' ')
+		case keyEscape:
+			switch e.readEscape() {
+			case 'A':
+				historyIndex, current = e.browse(l, historyIndex, historyIndex-1, current)
+			case 'B':
+				historyIndex, current = e.browse(l, historyIndex, historyIndex+1, current)
+			case 'C':
+				l.right()
+			case 'D':
+				l.left()
+			case 'H':
+				l.pos = 0
+			case 'F':
+				l.pos = len(l.buf)
+			case '3':
+				l.delete()
+			}
+		default:
+			if r >= ' ' {
+				l.insert(r)
+			}
+		}
+		e.refresh(prompt, l)
+	}
+}
+
+// 读取转义序列 ESC [ A 之类 返回代表按键的字符 不认识的序列返回0
+// 方向键 A上 B下 C右 D左 H行首 F行尾 3删除
+func (e *Editor) readEscape() rune {
+	r, _, err := e.reader.ReadRune()
+	if err != nil || (r != '[' && r != 'O') {
+		return 0
+	}
+	r, _, err = e.reader.ReadRune()
+	if err != nil {
+		return 0
+	}
+	if r < '0' || r > '9' {
+		return r
+	}
+	// ESC [ 数字 ~
+	code := r
+	for {
+		next, _, err := e.reader.ReadRune()
+		if err != nil {
+			return 0
+		}
+		if next == '~' {
+			break
+		}
+		if next < '0' || next > '9' {
+			return 0
+		}
+		code = -1 // 多位数字 不支持
+	}
+	switch code {
+	case '1', '7':
+		return 'H'
+	case '4', '8':
+		return 'F'
+	case '3':
+		return '3'
+	}
+	return 0
+}
+
+// 切换到第index条历史记录 返回新的下标和保存的当前输入
+func (e *Editor) browse(l *line, from, index int, current string) (int, string) {
+	if index < 0 || index > len(e.history) {
+		return from, current
+	}
+	if from == len(e.history) {
+		current = string(l.buf) // 离开正在编辑的输入之前先保存
+	}
+	if index == len(e.history) {
+		l.buf = []rune(current)
+	} else {
+		l.buf = []rune(e.history[index])
+	}
+	l.pos = len(l.buf)
+	return index, current
+}
+
+// 重新绘制当前行 回到行首 输出提示符和内容 清除行尾 再把光标移动到正确的位置
+func (e *Editor) refresh(prompt string, l *line) {
+	var out strings.Builder
+	out.WriteString("\r")
+	out.WriteString(prompt)
+	out.WriteString(strings.ReplaceAll(string(l.buf), "\n", " ")) // 多行的历史记录显示在一行中
+	out.WriteString("\x1b[K")
+	if back := width.String(string(l.buf[l.pos:])); back > 0 {
+		fmt.Fprintf(&out, "\x1b[%dD", back)
+	}
+	io.WriteString(e.out, out.String())
+}
+
+func (l *line) insert(r rune) {
+	l.buf = append(l.buf, 0)
+	copy(l.buf[l.pos+1:], l.buf[l.pos:])
+	l.buf[l.pos] = r
+	l.pos++
+}
+
+func (l *line) backspace() {
+	if l.pos > 0 {
+		l.buf = append(l.buf[:l.pos-1], l.buf[l.pos:]...)
+		l.pos--
+	}
+}
+
+func (l *line) delete() {
+	if l.pos < len(l.buf) {
+		l.buf = append(l.buf[:l.pos], l.buf[l.pos+1:]...)
+	}
+}
+
+// 删除光标前的一个单词
+func (l *line) deleteWord() {
+	end := l.pos
+	for l.pos > 0 && l.buf[l.pos-1] == ' ' {
+		l.pos--
+	}
+	for l.pos > 0 && l.buf[l.pos-1] != ' ' {
+		l.pos--
+	}
+	l.buf = append(l.buf[:l.pos], l.buf[end:]...)
+}
+
+func (l *line) left() {
+	if l.pos > 0 {
+		l.pos--
+	}
+}
+
+func (l *line) right() {
+	if l.pos < len(l.buf) {
+		l.pos++
+	}
+}
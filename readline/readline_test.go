@@ -0,0 +1,171 @@
+package readline
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+// 不经过终端 直接把按键序列交给编辑器
+func newTestEditor(keys string, history ...string) *Editor {
+	return &Editor{
+		reader:  bufio.NewReader(strings.NewReader(keys)),
+		out:     &bytes.Buffer{},
+		history: history,
+	}
+}
+
+func TestEdit(t *testing.T) {
+	tests := []struct {
+		keys     string
+		history  []string
+		expected string
+	}{
+		{"let a = 1;\r", nil, "let a = 1;"},
+		{"abc\x7f\x7fd\r", nil, "ad"},                     // 退格
+		{"ac\x1b[Db\r", nil, "abc"},                       // 左移后插入
+		{"ac\x02b\x06d\r", nil, "abcd"},                   // Ctrl-B Ctrl-F
+		{"bc\x01a\x05d\r", nil, "abcd"},                   // 行首 行尾
+		{"bc\x1b[Ha\x1b[Fd\r", nil, "abcd"},               // Home End
+		{"bc\x1b[1~a\x1b[4~d\r", nil, "abcd"},             // Home End 另一种序列
+		{"abc\x01\x1b[3~\r", nil, "bc"},                   // Delete
+		{"abc\x01\x04\r", nil, "bc"},                      // 有内容时Ctrl-D删除字符
+		{"abcdef\x02\x02\x0b\r", nil, "abcd"},             // Ctrl-K
+		{"abcdef\x02\x02\x15\r", nil, "ef"},               // Ctrl-U
+		{"let foo bar\x17\r", nil, "let foo "},            // Ctrl-W
+		{"\x1b[A\r", []string{"one", "two"}, "two"},       // 上一条历史
+		{"\x1b[A\x1b[A\r", []string{"one", "two"}, "one"}, // 再上一条
+		{"\x1b[A\x1b[A\x1b[A\r", []string{"one"}, "one"},  // 不会越过第一条
+		{"new\x1b[A\x1b[B\r", []string{"one"}, "new"},     // 回到正在编辑的输入
+		{"\x10x\r", []string{"one"}, "onex"},              // Ctrl-P
+		{"中文\x7f字\r", nil, "中字"},                          // 多字节字符
+		{"\tx\n", nil, "  x"},                             // Tab插入空格
+		{"partial", nil, "partial"},                       // 没有换行就结束输入
+	}
+
+	for _, tt := range tests {
+		e := newTestEditor(tt.keys, tt.history...)
+		line, err := e.edit(">> ")
+		if err != nil {
+			t.Errorf("keys %q: unexpected error %v", tt.keys, err)
+			continue
+		}
+		if line != tt.expected {
+			t.Errorf("keys %q: expected %q, got %q", tt.keys, tt.expected, line)
+		}
+	}
+}
+
+func TestEditInterruptAndEOF(t *testing.T) {
+	if _, err := newTestEditor("abc\x03").edit(">> "); err != ErrInterrupt {
+		t.Errorf("expected ErrInterrupt, got %v", err)
+	}
+	if _, err := newTestEditor("\x04").edit(">> "); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+	if _, err := newTestEditor("").edit(">> "); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+}
+
+func TestRefreshMovesCursor(t *testing.T) {
+	e := newTestEditor("ab\x1b[D中\x1b[D\r")
+	if _, err := e.edit(">> "); err != nil {
+		t.Fatal(err)
+	}
+	out := e.out.(*bytes.Buffer).String()
+	// 光标在 中 之前 后面还有 中b 宽度为3
+	if !strings.HasSuffix(out, "\r>> a中b\x1b[K\x1b[3D\r\n") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestReadPlainLine(t *testing.T) {
+	out := &bytes.Buffer{}
+	e := New(strings.NewReader("first\r\nsecond"), out)
+	if e.IsTerminal() {
+		t.Fatal("strings.Reader is not a terminal")
+	}
+	for _, expected := range []string{"first", "second"} {
+		line, err := e.Readline(">> ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if line != expected {
+			t.Errorf("expected %q, got %q", expected, line)
+		}
+	}
+	if _, err := e.Readline(">> "); err != io.EOF {
+		t.Errorf("expected io.EOF, got %v", err)
+	}
+	if out.String() != ">> >> >> " {
+		t.Errorf("unexpected prompts %q", out.String())
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := t.TempDir() + "/history"
+	e := New(strings.NewReader(""), io.Discard)
+	if err := e.LoadHistory(path); err != nil {
+		t.Fatalf("missing history file should not be an error: %v", err)
+	}
+	e.AddHistory("let a = 1;")
+	e.AddHistory("let a = 1;") // 重复
+	e.AddHistory("   ")        // 空行
+	e.AddHistory("a + 1")
+	e.AddHistory("let f = fn() {\n  1\n};") // 多行的输入是一条记录
+
+	loaded := New(strings.NewReader(""), io.Discard)
+	if err := loaded.LoadHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"let a = 1;", "a + 1", "let f = fn() {\n  1\n};"}
+	if strings.Join(loaded.History(), "|") != strings.Join(expected, "|") {
+		t.Errorf("expected history %q, got %q", expected, loaded.History())
+	}
+
+	for i := 0; i < MaxHistory+10; i++ {
+		e.AddHistory(strings.Repeat("x", i+1))
+	}
+	if len(e.History()) != MaxHistory {
+		t.Errorf("history should be capped at %d, got %d", MaxHistory, len(e.History()))
+	}
+	// 文件也只保留最近的MaxHistory条
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+	if len(lines) != MaxHistory || lines[len(lines)-1] != strings.Repeat("x", MaxHistory+10) {
+		t.Errorf("history file should keep the last %d entries, got %d", MaxHistory, len(lines))
+	}
+}
+
+// 之前保存的历史文件超过限制时 读取时重写
+func TestLoadHistoryTrimsFile(t *testing.T) {
+	path := t.TempDir() + "/history"
+	var data strings.Builder
+	for i := 0; i < MaxHistory+5; i++ {
+		fmt.Fprintf(&data, "line %d\n", i)
+	}
+	if err := os.WriteFile(path, []byte(data.String()), 0600); err != nil {
+		t.Fatal(err)
+	}
+	e := New(strings.NewReader(""), io.Discard)
+	if err := e.LoadHistory(path); err != nil {
+		t.Fatal(err)
+	}
+	e.AddHistory("last")
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	if len(lines) != MaxHistory || lines[0] != "line 6" || lines[len(lines)-1] != "last" {
+		t.Errorf("wrong history file: %d lines, first=%q, last=%q", len(lines), lines[0], lines[len(lines)-1])
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !linux && !darwin && !freebsd
+
+package readline
+
+import "errors"
+
+type termState struct{}
+
+// 其他平台不支持原始模式 退化为逐行读取
+func isTerminal(fd uintptr) bool {
+	return false
+}
+
+func makeRaw(fd uintptr) (*termState, error) {
+	return nil, errors.New("readline: raw mode is not supported on this platform")
+}
+
+func restore(fd uintptr, state *termState) error {
+	return nil
+}
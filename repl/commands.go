@@ -0,0 +1,187 @@
+package repl
+
+import (
+	"fmt"
+	"io"
+	"monkey/ast"
+	"monkey/lexer"
+	"monkey/parser"
+	"monkey/token"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// repl命令 以冒号开头 在词法分析之前处理
+type command struct {
+	usage string
+	help  string
+	run   func(s *session, arg string)
+}
+
+var commands map[string]command
+
+func init() {
+	// 在init中赋值 避免:help引用commands造成初始化循环
+	commands = map[string]command{
+		"env":    {":env", "列出会话中的绑定", (*session).cmdEnv},
+		"macros": {":macros", "列出定义的宏", (*session).cmdMacros},
+		"ast":    {":ast <expr>", "打印解析得到的语法树", (*session).cmdAST},
+		"tokens": {":tokens <expr>", "打印词法分析得到的词法单元", (*session).cmdTokens},
+		"type":   {":type <expr>", "求值并打印结果的类型", (*session).cmdType},
+		"load":   {":load <file>", "在当前会话中执行文件", (*session).cmdLoad},
+		"reset":  {":reset", "清空绑定 宏定义和虚拟机状态", (*session).cmdReset},
+		"help":   {":help", "列出所有命令", (*session).cmdHelp},
+	}
+}
+
+// 执行repl命令 line以冒号开头
+func (s *session) command(line string) {
+	name, arg, _ := strings.Cut(strings.TrimPrefix(line, ":"), " ")
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command :%s, type :help for a list of commands\n", name)
+		return
+	}
+	cmd.run(s, strings.TrimSpace(arg))
+}
+
+func (s *session) cmdHelp(arg string) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(s.out, "%-16s %s\n", commands[name].usage, commands[name].help)
+	}
+}
+
+// 虚拟机的全局绑定保存在符号表和globals中 求值器的保存在环境中
+func (s *session) cmdEnv(arg string) {
+	if s.engine == EngineVM {
+		for _, symbol := range s.symbolTable.DefinedSymbols() {
+			fmt.Fprintf(s.out, "%s = %s\n", symbol.Name, s.globals[symbol.Index].Inspect())
+		}
+		return
+	}
+	for _, name := range s.env.Names() {
+		value, _ := s.env.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, value.Inspect())
+	}
+}
+
+func (s *session) cmdMacros(arg string) {
+	for _, name := range s.macroEnv.Names() {
+		value, _ := s.macroEnv.Get(name)
+		fmt.Fprintf(s.out, "%s = %s\n", name, value.Inspect())
+	}
+}
+
+func (s *session) cmdAST(arg string) {
+	p := parser.New(lexer.New(arg))
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		printParserErrors(s.out, arg, p.Diagnostics())
+		return
+	}
+	dumpNode(s.out, program, 0)
+}
+
+func (s *session) cmdTokens(arg string) {
+	l := lexer.New(arg)
+	for {
+		tok := l.NextToken()
+		fmt.Fprintf(s.out, "%-6s %-10s %q\n", tok.Pos, tok.Type, tok.Literal)
+		if tok.Type == token.EOF {
+			break
+		}
+	}
+	for _, e := range l.Errors() {
+		fmt.Fprintf(s.out, "%s: %s\n", e.Start, e.Message)
+	}
+}
+
+func (s *session) cmdType(arg string) {
+	if arg == "" {
+		io.WriteString(s.out, "usage: :type <expr>\n")
+		return
+	}
+	if result := s.eval("", arg); result != nil {
+		fmt.Fprintln(s.out, result.Type())
+	}
+}
+
+func (s *session) cmdLoad(arg string) {
+	if arg == "" {
+		io.WriteString(s.out, "usage: :load <file>\n")
+		return
+	}
+	source, err := os.ReadFile(arg)
+	if err != nil {
+		fmt.Fprintf(s.out, "cannot load %s: %s\n", arg, err)
+		return
+	}
+	printResult(s.out, s.eval(arg, string(source)))
+}
+
+func (s *session) cmdReset(arg string) {
+	s.reset()
+	io.WriteString(s.out, "session reset\n")
+}
+
+var nodeType = reflect.TypeOf((*ast.Node)(nil)).Elem()
+
+// 以缩进的形式打印语法树 每行是节点类型和它的词法单元
+// 通过反射遍历字段中的子节点 新增节点类型时不需要修改
+func dumpNode(out io.Writer, node ast.Node, depth int) {
+	v := reflect.ValueOf(node)
+	if v.Kind() == reflect.Ptr && v.IsNil() {
+		return
+	}
+	label := reflect.Indirect(v).Type().Name()
+	if _, ok := node.(*ast.Program); !ok {
+		label += " " + node.TokenLiteral()
+	}
+	fmt.Fprintf(out, "%s%s\n", strings.Repeat("  ", depth), label)
+
+	v = reflect.Indirect(v)
+	if v.Kind() != reflect.Struct {
+		return
+	}
+	for i := 0; i < v.NumField(); i++ {
+		field := v.Field(i)
+		if !v.Type().Field(i).IsExported() {
+			continue
+		}
+		switch {
+		case field.Kind() == reflect.Slice:
+			for j := 0; j < field.Len(); j++ {
+				dumpValue(out, field.Index(j), depth+1)
+			}
+		case field.Kind() == reflect.Map:
+			// 哈希字面量 按键的字符串形式排序 保证输出稳定
+			keys := field.MapKeys()
+			sort.Slice(keys, func(a, b int) bool {
+				return fmt.Sprint(keys[a].Interface()) < fmt.Sprint(keys[b].Interface())
+			})
+			for _, key := range keys {
+				dumpValue(out, key, depth+1)
+				dumpValue(out, field.MapIndex(key), depth+2)
+			}
+		default:
+			dumpValue(out, field, depth+1)
+		}
+	}
+}
+
+func dumpValue(out io.Writer, v reflect.Value, depth int) {
+	if !v.Type().Implements(nodeType) {
+		return
+	}
+	if (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) && v.IsNil() {
+		return
+	}
+	dumpNode(out, v.Interface().(ast.Node), depth)
+}
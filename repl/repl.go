@@ -3,48 +3,239 @@
 package repl
 
 import (
-	"bufio"
 	"fmt"
 	"io"
+	"monkey/compiler"
 	"monkey/evaluator"
 	"monkey/lexer"
 	"monkey/object"
 	"monkey/parser"
+	"monkey/readline"
+	"monkey/vm"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
-const PROMPT = ">> " // prompt
-// 读取命令行输入的源代码
+const PROMPT = ">> "           // prompt
+const CONTINUE_PROMPT = "... " // 输入没有结束时 继续输入的提示符
+
+const HISTORY_FILE = ".monkey_history" // 保存在用户主目录下的历史记录文件
+
+// 执行引擎
+const (
+	EngineEval = "eval" // 树遍历求值器
+	EngineVM   = "vm"   // 字节码编译器 + 虚拟机
+)
+
+// 执行的设置 只影响这个会话
+type Options struct {
+	Engine            string // EngineEval 或者 EngineVM
+	CheckedArithmetic bool   // 整数运算的结果超出int64范围时报错
+}
+
+// 读取命令行输入的源代码 使用求值器执行
 func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
-	macroEnv := object.NewEnvironment()
+	StartEngine(in, out, EngineEval)
+}
+
+// 使用指定的引擎执行
+func StartEngine(in io.Reader, out io.Writer, engine string) {
+	StartWithOptions(in, out, Options{Engine: engine})
+}
+
+// 使用指定的设置执行
+func StartWithOptions(in io.Reader, out io.Writer, opts Options) {
+	editor := readline.New(in, out)
+	if editor.IsTerminal() { // 只记录交互式输入的历史
+		if home, err := os.UserHomeDir(); err == nil {
+			editor.LoadHistory(filepath.Join(home, HISTORY_FILE))
+		}
+	}
+	s := newSession(out, opts)
 	for {
-		fmt.Fprintf(out, PROMPT)
-		scanned := scanner.Scan()
-		if !scanned {
+		line, err := readInput(editor)
+		if err == readline.ErrInterrupt { // Ctrl-C 放弃已经输入的内容
+			continue
+		}
+		if err != nil {
 			return
 		}
-		line := scanner.Text()
-		l := lexer.New(line)
-		p := parser.New(l)
-		program := p.ParseProgram()
-		if len(p.Errors()) != 0 {
-			printParserErrors(out, p.Errors())
+		if strings.HasPrefix(strings.TrimSpace(line), ":") { // 冒号开头的是repl命令 不交给词法分析器
+			s.command(strings.TrimSpace(line))
 			continue
 		}
-		evaluator.DefineMacros(program, macroEnv)
-		expanded := evaluator.ExpandMacros(program, macroEnv)
-		evaluated := evaluator.Eval(expanded, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, "\n")
+		printResult(out, s.eval("", line))
+	}
+}
+
+// repl会话的状态 多次输入之间共享
+type session struct {
+	out      io.Writer
+	engine   string
+	checked  bool                // 整数运算溢出时报错
+	env      *object.Environment // 求值器的绑定
+	macroEnv *object.Environment // 宏定义
+	// 虚拟机的状态 多次输入之间共享全局绑定和常量池
+	constants   []object.Object
+	globals     []object.Object
+	symbolTable *compiler.SymbolTable
+}
+
+func newSession(out io.Writer, opts Options) *session {
+	s := &session{out: out, engine: opts.Engine, checked: opts.CheckedArithmetic}
+	s.reset()
+	return s
+}
+
+// 清空所有绑定 宏定义和虚拟机状态
+func (s *session) reset() {
+	s.env = object.NewEnvironment()
+	s.env.CallStack().CheckedArithmetic = s.checked
+	s.macroEnv = object.NewEnvironment()
+	s.constants = []object.Object{}
+	s.globals = make([]object.Object, vm.GlobalsSize)
+	s.symbolTable = compiler.NewGlobalSymbolTable()
+}
+
+// 打印求值的结果 错误先打印调用链 nil表示没有需要打印的值
+func printResult(out io.Writer, result object.Object) {
+	if result == nil {
+		return
+	}
+	if err, ok := result.(*object.Error); ok {
+		io.WriteString(out, err.Traceback())
+	}
+	io.WriteString(out, result.Inspect())
+	io.WriteString(out, "\n")
+}
+
+// 解析 展开宏 然后使用会话的引擎执行 出错时打印错误并返回nil
+// 返回nil也表示没有需要打印的值
+func (s *session) eval(filename, source string) object.Object {
+	l := lexer.NewWithFilename(filename, source)
+	p := parser.New(l)
+	program := p.ParseProgram()
+	if len(p.Diagnostics()) != 0 {
+		printParserErrors(s.out, source, p.Diagnostics())
+		return nil
+	}
+	evaluator.DefineMacros(program, s.macroEnv)
+	expanded := evaluator.ExpandMacros(program, s.macroEnv)
+	if s.engine != EngineVM {
+		return evaluator.Eval(expanded, s.env)
+	}
+	comp := compiler.NewWithState(s.symbolTable, s.constants)
+	if err := comp.Compile(expanded); err != nil {
+		s.symbolTable.ForgetUnset(s.globals)
+		fmt.Fprintf(s.out, "Woops! Compilation failed:\n %s\n", err)
+		return nil
+	}
+	bytecode := comp.Bytecode()
+	s.constants = bytecode.Constants
+	machine := vm.NewWithGlobalsStore(bytecode, s.globals)
+	machine.CheckedArithmetic = s.checked
+	err := machine.Run()
+	s.symbolTable.ForgetUnset(s.globals) // 出错时之后的let没有执行 和求值器一样这些名称没有定义
+	if err != nil {
+		fmt.Fprintf(s.out, "Woops! Executing bytecode failed:\n %s\n", err)
+		return nil
+	}
+	if !compiler.EndsWithValue(program) && !machine.Halted() { // 和求值器一致 let语句不产生值 不打印
+		return nil
+	}
+	return machine.LastPoppedStackElem()
+}
+
+// 读取一次完整的输入 括号没有闭合或者字符串没有结束时继续读取下一行
+func readInput(editor *readline.Editor) (string, error) {
+	var lines []string
+	prompt := PROMPT
+	for {
+		line, err := editor.Readline(prompt)
+		if err != nil {
+			if err == io.EOF && len(lines) > 0 { // 输入结束时执行已经读取的部分 让解析器报告错误
+				return strings.Join(lines, "\n"), nil
+			}
+			return "", err
+		}
+		lines = append(lines, line)
+		input := strings.Join(lines, "\n")
+		if !isIncomplete(input) {
+			editor.AddHistory(input) // 多行的输入作为一条历史记录 可以整体调出
+			return input, nil
 		}
+		prompt = CONTINUE_PROMPT
 	}
 }
-func printParserErrors(out io.Writer, errors []string) {
+
+// 输入是否还没有结束 有没有闭合的 { ( [ 或者没有结束的字符串 原始字符串和块注释可以跨行
+// 多出来的右括号不算没有结束 交给解析器报错 注释中的括号和引号不算
+func isIncomplete(input string) bool {
+	depth := 0
+	var quote rune // 所在字符串的引号 " 或者 ` 为0表示不在字符串中
+	escaped := false
+	comment := 0 // 所在块注释的嵌套层数
+	runes := []rune(input)
+	for i := 0; i < len(runes); i++ {
+		ch := runes[i]
+		var next rune
+		if i+1 < len(runes) {
+			next = runes[i+1]
+		}
+		if comment > 0 {
+			switch {
+			case ch == '/' && next == '*':
+				comment++
+				i++
+			case ch == '*' && next == '/':
+				comment--
+				i++
+			}
+			continue
+		}
+		if quote != 0 {
+			switch {
+			case escaped:
+				escaped = false
+			case ch == '\\' && quote == '"': // 原始字符串没有转义
+				escaped = true
+			case ch == quote:
+				quote = 0
+			}
+			continue
+		}
+		switch ch {
+		case '/':
+			if next == '/' { // 行注释 跳到行尾
+				for i < len(runes) && runes[i] != '\n' {
+					i++
+				}
+			} else if next == '*' {
+				comment++
+				i++
+			}
+		case '"', '`':
+			quote = ch
+		case '{', '(', '[':
+			depth++
+		case '}', ')', ']':
+			depth--
+		}
+	}
+	return quote != 0 || comment > 0 || depth > 0
+}
+
+// 打印语法错误 在出错的源码行下面用 ^ 标出出错的列
+func printParserErrors(out io.Writer, source string, diagnostics []parser.Diagnostic) {
 	io.WriteString(out, "woops! we ran into some monkey business here!\n")
 	io.WriteString(out, " parser errors:\n")
-	for _, msg := range errors {
-		io.WriteString(out, "\t"+msg+"\n")
+	for _, d := range diagnostics {
+		fmt.Fprintf(out, "\t%s: %s[%s]: %s\n", d.Span.Start, d.Severity, d.Code, d.Message)
+		if snippet := d.Snippet(source); snippet != "" {
+			for _, line := range strings.Split(snippet, "\n") {
+				io.WriteString(out, "\t"+line+"\n")
+			}
+		}
 	}
 }
@@ -0,0 +1,199 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"monkey/readline"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestIsIncomplete(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"let a = 1;", false},
+		{"let add = fn(a, b) {", true},
+		{"let add = fn(a, b) {\n a + b\n};", false},
+		{"add(1,", true},
+		{"[1, 2", true},
+		{`let s = "hello`, true},
+		{`let s = "{";`, false},
+		{"}", false},    // 多余的右括号交给解析器报错
+		{`"a\"b`, true}, // 转义的引号不会结束字符串
+		{`"a\\"`, false},
+		{"let s = `raw", true},
+		{"let s = `a\\`", false}, // 原始字符串没有转义
+		{"`{`", false},
+		{"let a = 1; // {", false}, // 注释中的括号不算
+		{"// \"\nlet a = 1;", false},
+		{"/* ( */ 1", false},
+		{"/* a", true},
+		{"/* a /* b */ c", true}, // 块注释可以嵌套
+		{"/* a /* b */ c */", false},
+		{`"/*"`, false},
+		{"10 / 2 * (3", true},
+	}
+
+	for _, tt := range tests {
+		if got := isIncomplete(tt.input); got != tt.expected {
+			t.Errorf("isIncomplete(%q) = %t, expected %t", tt.input, got, tt.expected)
+		}
+	}
+}
+
+func TestMultiLineInput(t *testing.T) {
+	input := "let add = fn(a, b) {\n  a + b\n};\nadd(1,\n2)\n\"a\nb\"\n"
+	for _, engine := range []string{EngineEval, EngineVM} {
+		out := &bytes.Buffer{}
+		StartEngine(strings.NewReader(input), out, engine)
+		expected := ">> ... ... >> ... 3\n>> ... a\nb\n>> "
+		if out.String() != expected {
+			t.Errorf("engine %s: expected output %q, got %q", engine, expected, out.String())
+		}
+	}
+}
+
+func TestParserErrorsInMultiLineInput(t *testing.T) {
+	out := &bytes.Buffer{}
+	Start(strings.NewReader("let a = [1,\n  2 3];\n"), out)
+	if !strings.Contains(out.String(), "2:5:") || !strings.Contains(out.String(), "\t  2 3];\n") {
+		t.Errorf("expected error positioned in the second line, got %q", out.String())
+	}
+}
+
+// 执行输入 返回去掉提示符之后的输出
+func runSession(engine, input string) string {
+	out := &bytes.Buffer{}
+	StartEngine(strings.NewReader(input), out, engine)
+	return strings.ReplaceAll(out.String(), PROMPT, "")
+}
+
+func TestEnvAndResetCommands(t *testing.T) {
+	input := "let b = 2;\nlet a = 1;\n:env\n:reset\n:env\na\n"
+	for _, engine := range []string{EngineEval, EngineVM} {
+		expected := "a = 1\nb = 2\nsession reset\nERROR: 1:1: identifier not found: a\n"
+		if engine == EngineVM { // 虚拟机按定义顺序列出
+			expected = "b = 2\na = 1\nsession reset\nERROR: 1:1: identifier not found: a\n"
+		}
+		if got := runSession(engine, input); got != expected {
+			t.Errorf("engine %s: expected %q, got %q", engine, expected, got)
+		}
+	}
+}
+
+// 出错之后的let没有执行 名称没有定义 不会留下没有值的全局绑定
+func TestErrorLeavesLaterLetsUndefined(t *testing.T) {
+	input := "let a = 1; 1 / 0; let c = 2;\n:env\nlet c = 3; c\n"
+	expected := "ERROR: 1:14: division by zero\na = 1\n3\n"
+	for _, engine := range []string{EngineEval, EngineVM} {
+		if got := runSession(engine, input); got != expected {
+			t.Errorf("engine %s: expected %q, got %q", engine, expected, got)
+		}
+	}
+	input = "if (false) { let c = 1 }; let g = fn() { c };\ng()\nlet c = 5; g()\n"
+	expected = "Traceback (most recent call last):\n" +
+		"  line 1, column 2, in <program>\n  line 1, column 42, in g(0 args)\nERROR: 1:42: identifier not found: c\n5\n"
+	for _, engine := range []string{EngineEval, EngineVM} {
+		got := runSession(engine, input)
+		if got != expected {
+			t.Errorf("engine %s: expected %q, got %q", engine, expected, got)
+		}
+	}
+}
+
+func TestMacrosCommand(t *testing.T) {
+	got := runSession(EngineEval, "let unless = macro(c, x) { quote(if (!(unquote(c))) { unquote(x) }) };\n:macros\n")
+	if !strings.HasPrefix(got, "unless = macro(c, x) {") {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestASTCommand(t *testing.T) {
+	expected := `Program
+  ExpressionStatement add
+    CallExpression (
+      Identifier add
+      IntegerLiteral 1
+      InfixExpression *
+        IntegerLiteral 2
+        IntegerLiteral 3
+`
+	if got := runSession(EngineEval, ":ast add(1, 2 * 3)\n"); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestTokensCommand(t *testing.T) {
+	expected := "1:1    IDENT      \"a\"\n1:3    +          \"+\"\n1:5    INT        \"1\"\n1:6    EOF        \"\"\n"
+	if got := runSession(EngineEval, ":tokens a + 1\n"); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+}
+
+func TestTypeCommand(t *testing.T) {
+	input := ":type 1\n:type \"s\"\n:type [1]\n:type {}\n:type fn(x) { x }\n:type len\n"
+	expected := map[string]string{
+		EngineEval: "INTEGER\nSTRING\nARRAY\nHASH\nFUNCTION\nBUILTIN\n",
+		EngineVM:   "INTEGER\nSTRING\nARRAY\nHASH\nCLOSURE\nBUILTIN\n",
+	}
+	for engine, want := range expected {
+		if got := runSession(engine, input); got != want {
+			t.Errorf("engine %s: expected %q, got %q", engine, want, got)
+		}
+	}
+}
+
+func TestLoadCommand(t *testing.T) {
+	path := t.TempDir() + "/lib.mk"
+	if err := os.WriteFile(path, []byte("let double = fn(x) { x * 2 };\ndouble(2)\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, engine := range []string{EngineEval, EngineVM} {
+		expected := "4\n6\n"
+		if got := runSession(engine, ":load "+path+"\ndouble(3)\n"); got != expected {
+			t.Errorf("engine %s: expected %q, got %q", engine, expected, got)
+		}
+	}
+
+	got := runSession(EngineEval, ":load "+path+".missing\n")
+	if !strings.HasPrefix(got, "cannot load ") {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestUnknownCommand(t *testing.T) {
+	got := runSession(EngineEval, ":nope\n")
+	if got != "unknown command :nope, type :help for a list of commands\n" {
+		t.Errorf("unexpected output %q", got)
+	}
+}
+
+func TestErrorTraceback(t *testing.T) {
+	input := "let f = fn(x) { let r = len(x); r };\nf(1)\n"
+	expected := "Traceback (most recent call last):\n" +
+		"  line 1, column 2, in <program>\n" +
+		"  line 1, column 28, in f(1 arg)\n" +
+		"ERROR: 1:28: argument to `len` not supported, got INTEGER\n"
+	for _, engine := range []string{EngineEval, EngineVM} {
+		if got := runSession(engine, input); got != expected {
+			t.Errorf("%s: expected %q, got %q", engine, expected, got)
+		}
+	}
+}
+
+// 多行的输入在历史记录中是一条 可以整体调出
+func TestMultiLineHistory(t *testing.T) {
+	editor := readline.New(strings.NewReader("let f = fn() {\n  1\n};\nf()\n"), io.Discard)
+	for i := 0; i < 2; i++ {
+		if _, err := readInput(editor); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []string{"let f = fn() {\n  1\n};", "f()"}
+	if strings.Join(editor.History(), "|") != strings.Join(expected, "|") {
+		t.Errorf("expected history %q, got %q", expected, editor.History())
+	}
+}
@@ -1,12 +1,17 @@
 package token
 
+import "fmt"
+
 const (
 	ILLEGAL = "ILLEGAL" // 未知的类型 非法类型等
 	EOF     = "EOF"     // end of file
 	// 标识符 + 字面量
 	IDENT  = "IDENT"  // 变量标识符
 	INT    = "INT"    // 数字
+	FLOAT  = "FLOAT"  // 浮点数 3.14 1e-9
 	STRING = "STRING" // 字符串
+	// 注释 // 行注释 /* 块注释 */ 不会交给解析器 只保存下来供格式化和文档工具使用
+	COMMENT = "COMMENT"
 	// 运算符
 	ASSIGN   = "="
 	PLUS     = "+"
@@ -14,12 +19,32 @@ const (
 	BANG     = "!"
 	ASTERISK = "*"
 	SLASH    = "/"
+	PERCENT  = "%"
+	POWER    = "**"
 	LT       = "<"
 	GT       = ">"
+	// 位运算符
+	BIT_AND = "&"
+	BIT_OR  = "|"
+	BIT_XOR = "^"
+	BIT_NOT = "~"
+	SHL     = "<<"
+	SHR     = ">>"
 	// 等于 不等于运算符 为什么在这里指出来？ 因为这是两个字符构成的 也就是双字符的运算符
 	EQ     = "=="
 	NOT_EQ = "!="
-	// TODO 支持更多双字符运算符 >= <= && || 如果支持二进制 还可以支持 >> << 那么应该抽取成方法了 比如 makeTwoCharToken
+	LT_EQ  = "<="
+	GT_EQ  = ">="
+	// 逻辑运算符 短路求值
+	AND = "&&"
+	OR  = "||"
+	// 复合赋值运算符 x += 1 相当于 x = x + 1
+	PLUS_ASSIGN     = "+="
+	MINUS_ASSIGN    = "-="
+	ASTERISK_ASSIGN = "*="
+	SLASH_ASSIGN    = "/="
+	PERCENT_ASSIGN  = "%="
+	POWER_ASSIGN    = "**="
 	// 分割符
 	COMMA     = ","
 	SEMICOLON = ";"
@@ -41,6 +66,17 @@ const (
 	ELSE     = "ELSE"
 	RETURN   = "RETURN"
 	MACRO    = "MACRO"
+	// 循环
+	WHILE    = "WHILE"
+	FOR      = "FOR"
+	IN       = "IN"
+	BREAK    = "BREAK"
+	CONTINUE = "CONTINUE"
+	// 异常处理
+	TRY     = "TRY"
+	CATCH   = "CATCH"
+	FINALLY = "FINALLY"
+	THROW   = "THROW"
 )
 
 type TokenType string
@@ -48,18 +84,57 @@ type TokenType string
 type Token struct {
 	Type    TokenType // 类型
 	Literal string    // 字面量值
+	Pos     Position  // 词法单元第一个字符在源码中的位置
+	End     Position  // 词法单元最后一个字符之后的位置 字符串的字面量是解码后的值 长度和源码不同
+}
+
+// 源码中的位置
+type Position struct {
+	Filename string // 文件名 repl等没有文件的输入为空
+	Offset   int    // 字节偏移量 从0开始
+	Line     int    // 行号 从1开始
+	Column   int    // 列号 从1开始 按字符(rune)计数 不是字节
+}
+
+// 是否是有效的位置 没有经过词法分析的节点(比如宏展开生成的节点)位置为空
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// file:line:col 没有文件名时为 line:col
+func (p Position) String() string {
+	s := p.Filename
+	if p.IsValid() {
+		if s != "" {
+			s += ":"
+		}
+		s += fmt.Sprintf("%d:%d", p.Line, p.Column)
+	}
+	if s == "" {
+		s = "-"
+	}
+	return s
 }
 
 // 定义的关键字获取对应的类型
 var keywords = map[string]TokenType{
-	"fn":     FUNCTION,
-	"let":    LET,
-	"true":   TRUE,
-	"false":  FALSE,
-	"if":     IF,
-	"else":   ELSE,
-	"return": RETURN,
-	"macro":  MACRO,
+	"fn":       FUNCTION,
+	"let":      LET,
+	"true":     TRUE,
+	"false":    FALSE,
+	"if":       IF,
+	"else":     ELSE,
+	"return":   RETURN,
+	"macro":    MACRO,
+	"while":    WHILE,
+	"for":      FOR,
+	"in":       IN,
+	"break":    BREAK,
+	"continue": CONTINUE,
+	"try":      TRY,
+	"catch":    CATCH,
+	"finally":  FINALLY,
+	"throw":    THROW,
 }
 
 // 检查关键字 如果标识符ident是关键字 那就返回对应的常量 否则返回标识符IDENT
@@ -0,0 +1,20 @@
+package vm
+
+import "monkey/object"
+
+// 被闭包捕获并且会被赋值的绑定 定义它的函数和捕获它的闭包共用同一个cell
+// 赋值写入cell 所有地方都能看到新的值 cell只存在于局部绑定和自由变量中 读取时取出其中的值
+type cell struct {
+	value object.Object
+}
+
+func (c *cell) Type() object.ObjectType { return "CELL" }
+func (c *cell) Inspect() string         { return c.value.Inspect() }
+
+// 取出cell中的值 不是cell的原样返回
+func deref(obj object.Object) object.Object {
+	if c, ok := obj.(*cell); ok {
+		return c.value
+	}
+	return obj
+}
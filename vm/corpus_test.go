@@ -0,0 +1,261 @@
+package vm
+
+import (
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/object"
+	"testing"
+)
+
+// 两种后端共用的测试程序 求值器和虚拟机的结果Inspect()必须完全一致 都不产生值也算一致
+// 多键hash的Inspect()顺序不固定 不放在这里
+var corpus = []string{
+	`1 + 2 * 3 - 4 / 2`,
+	`-(5 + 5) * 2`,
+	`!true == false`,
+	`"Hello" + " " + "World!"`,
+	`[1, 2 * 2, 3 + 3]`,
+	`[1, 2, 3][1 + 1]`,
+	`[1, 2, 3][3]`,
+	`{"one": 10 - 9}["one"]`,
+	`{true: 5}[false]`,
+	`if (1 > 2) { 10 }`,
+	`if (1 < 2) { 10 } else { 20 }`,
+	`let a = 5; let b = a * 2; a + b`,
+	`return 10; 9;`,
+	`let add = fn(a, b) { a + b }; add(1, add(2, 3))`,
+	`let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(3)`,
+	`let fibonacci = fn(x) { if (x < 2) { x } else { fibonacci(x - 1) + fibonacci(x - 2) } }; fibonacci(15)`,
+	`
+	let map = fn(arr, f) {
+		let iter = fn(arr, accumulated) {
+			if (len(arr) == 0) {
+				accumulated
+			} else {
+				iter(rust(arr), push(accumulated, f(first(arr))));
+			}
+		};
+		iter(arr, []);
+	};
+	map([1, 2, 3, 4], fn(x) { x * 2 })
+	`,
+	`
+	let reduce = fn(arr, initial, f) {
+		let iter = fn(arr, result) {
+			if (len(arr) == 0) {
+				result
+			} else {
+				iter(rust(arr), f(result, first(arr)));
+			}
+		};
+		iter(arr, initial);
+	};
+	reduce([1, 2, 3, 4, 5], 0, fn(acc, x) { acc + x })
+	`,
+	`len("four") + len([1, 2])`,
+	`last([1, 2, 3])`,
+	`first([])`,
+	`pop([1, 2, 3])`,
+	`quote(1 + 2)`,
+	`5 + true; 5;`,
+	`"Hello" - "World"`,
+	`-true`,
+	`len(1)`,
+	`len("one", "two")`,
+	`{"name": "Monkey"}[[]]`,
+	`1[0]`,
+	"let f = fn(x) {\n  x + true\n};\nf(1)",
+	`3.14 * 2`,
+	`1 + 0.5 - -1.5`,
+	`1 / 4.0 < 0.3`,
+	`{1: "one"}[1.0]`,
+	`[int(2.7), float(3), floor(-0.5), ceil(0.5), round(2.5)]`,
+	`-1e-9`,
+	`1.5 - true`,
+	`[12 & 10, 12 | 10, 12 ^ 10, ~5, 1 << 10, -16 >> 2]`,
+	`let checksum = fn(x) { (x ^ (x >> 4)) & 0xf }; checksum(0xab)`,
+	// 移位和其他整数运算一样 结果超出int64范围时提升为大整数 不会回绕
+	`[1 << 63, 1 << 64, -1 << 63, 1 >> 64, -5 >> 1]`,
+	`let kind = fn(f) { try { f() } catch (e) { e["kind"] } }; [kind(fn() { 1 << -1 }), kind(fn() { 1 >> -1 }), kind(fn() { 1 << true })]`,
+	`~"a"`,
+	`[true && false, false || true, 1 && 2, false || 7, if (false) { 1 } && 2]`,
+	`true || len(1)`,
+	`true && len(1)`,
+	`let between = fn(x, lo, hi) { lo <= x && x <= hi }; [between(5, 1, 10), between(0, 1, 10)]`,
+	`[1 <= 2, 2 >= 3, 1.5 >= 1, "a" <= "b", "b" >= "c"]`,
+	`"a" >= 1`,
+	`[len("中文"), "中文"[1], "a😀b"[1], "abc"[3]]`,
+	// 注释
+	"// 行注释\nlet a = 10 / 2; /* 块注释 /* 嵌套 */ */ a // 结尾",
+	`let 加 = fn(甲, 乙) { 甲 + 乙 }; 加(1, 2)`,
+	// 赋值
+	`let x = 1; x = x + 1; x *= 10; x -= 5; x /= 3; x`,
+	`let s = "a"; s += "b"; [s, s = "c", s]`,
+	`let count = 0; let inc = fn(n) { count += n }; inc(2); inc(3); count`,
+	`let counter = fn() { let n = 0; fn() { n += 1 } }; let a = counter(); let b = counter(); a(); a(); [a(), b()]`,
+	`let f = fn(n) { let g = fn() { n }; n = n * 2; [g(), n] }; f(4)`,
+	`let f = fn() { let a = 1; let b = fn() { fn() { a = a + 1 } }; b()(); b()(); a }; f()`,
+	`let a = [1, 2, 3]; let b = a; b[0] = 10; a[1] += 5; a`,
+	`let h = {"n": 1}; h["n"] *= 3; h["m"] = 2; [h["n"], h["m"]]`,
+	`let m = [[1, 2], [3, 4]]; m[1][1] = 0; m`,
+	`let a = [1]; a[5] = 1`,
+	`let x = 1; x += "a"`,
+	// 循环
+	`let i = 0; let sum = 0; while (i < 10) { i += 1; sum += i; } sum`,
+	`let out = []; for (x in [3, 1, 2]) { out = push(out, x * x); } out`,
+	`let out = []; for (k in {"b": 1, 2: 2, true: 3, "a": 4}) { out = push(out, k); } out`,
+	`let s = ""; for (c in "中文ab") { s = c + s; } s`,
+	`let out = []; for (i in [1, 2, 3, 4, 5, 6]) { if ((i & 1) == 0) { continue; } if (i > 4) { break; } out = push(out, i); } out`,
+	`let n = 0; for (a in [1, 2, 3]) { for (b in [1, 2, 3]) { if (b > a) { break; } n += 1; } } n`,
+	`let find = fn(arr, v) { let i = 0; for (x in arr) { if (x == v) { return i; } i += 1; } -1 }; [find([5, 6, 7], 7), find([5], 1)]`,
+	`for (x in 1) { x }`,
+	// 循环变量和循环体中的let在函数中只有一个绑定 闭包看到的是最后一次绑定的值
+	`let f = fn() { let fs = []; for (i in [1, 2, 3]) { fs = push(fs, fn() { i }) } fs[0]() }; f()`,
+	`let f = fn() { let fs = []; let k = 0; while (k < 3) { let j = k; fs = push(fs, fn() { j }); k += 1 } fs[0]() }; f()`,
+	`let f = fn() { let fs = []; for (i in [1, 2]) { try { throw i } catch (e) { fs = push(fs, fn() { e["message"] }) } } [fs[0](), fs[1]()] }; f()`,
+	`let f = fn() { let x = 1; let g = fn() { x }; let x = 2; g() }; f()`,
+	// 异常处理
+	`try { throw "bad" } catch (e) { [e["message"], e["kind"]] }`,
+	`try { 1 + true } catch (e) { e["message"] }`,
+	`let f = fn(n) { if (n == 0) { throw {"message": "empty", "kind": "ValueError"} } n }; let r = try { f(0) } catch (e) { e["kind"] }; [r, f(2)]`,
+	`let log = []; let f = fn() { try { return 1 } finally { log = push(log, "f") } }; [f(), log]`,
+	`let out = []; for (i in [1, 2, 3, 4]) { try { if (i == 2) { continue } if (i == 4) { break } out = push(out, i) } catch (e) { 0 } finally { out = push(out, 0) } } out`,
+	`let n = 0; let r = try { try { throw "x" } finally { n += 1 } } catch (e) { e["message"] + "!" }; [r, n]`,
+	`throw "uncaught"`,
+	// 调用链 最内层的调用在前
+	`let f = fn(n) { if (n == 0) { throw "x" } 1 + f(n - 1) }; try { f(50) } catch (e) { [len(e["trace"]), e["trace"][0], e["trace"][50]] }`,
+	`let g = fn(a, b) { a / b }; let h = fn(x) { if (x) { return g(1, 0) } 0 }; let k = fn() { 1 + h(true) }; try { k() } catch (e) { e["trace"] }`,
+	`let g = fn(a, b) { let r = a / b; r }; let h = fn(x) { if (x) { g(1, 0) } else { 0 } }; try { h(true) } catch (e) { e["trace"] }`,
+	`let f = fn(x) { x }; let g = fn() { f() }; try { g() } catch (e) { e["trace"] }`,
+	`try { fn() { len(1) }() } catch (e) { e["trace"] }`,
+	`let g = fn() { 1 / 0 }; let h = fn() { g() }; let f = fn() { let x = h(); x }; try { f() } catch (e) { e["trace"] }`,
+	`let c = fn(n) { if (n == 0) { return len(n) } return c(n - 1) }; try { c(3) } catch (e) { e["trace"] }`,
+	// 函数显示源码 最后一条语句不产生值时没有结果
+	`let add = fn(a, b) { a + b }; add`,
+	`let newAdder = fn(x) { fn(y) { x + y } }; [newAdder(1), fn() { }]`,
+	`if (true) { let y = 1 }`,
+	`if (true) { if (true) { let y = 1 } }`,
+	`if (false) { let y = 1 } else { 2 }`,
+	`if (true) { }`,
+	`try { let z = 1 } catch (e) { 1 }`,
+	`let x = 1;`,
+	`for (x in [1]) { x }`,
+	`try { len(1) } finally { 1 }`,
+	`let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(5000)`,
+	`let f = fn(n) { f(n + 1) + 1 }; try { f(0) } catch (e) { e["message"] }`,
+	// 错误的种类
+	`let kind = fn(f) { try { f(); "ok" } catch (e) { e["kind"] } }; [kind(fn() { 1 + true }), kind(fn() { [1][3] = 0 }), kind(fn() { len(1, 2) }), kind(fn() { 1 })]`,
+	`fn(x) { x }()`,
+	`fn() { 1 }(1, 2)`,
+	`let f = fn(a, b) { a + b }; 1 + f(1)`,
+	`!(1 + true)`,
+	`[1, -"a", 3]`,
+	// 除以0
+	`1 / 0`,
+	`let f = fn(n) { 100 / n }; let r = try { f(0) } catch (e) { e["kind"] }; [r, f(3)]`,
+	`9223372036854775807 + 1`,
+	// 大整数
+	`let fact = fn(n) { if (n < 2) { 1 } else { n * fact(n - 1) } }; [fact(30), fact(30) / fact(28)]`,
+	`[9223372036854775808, -9223372036854775808, -(-9223372036854775807 - 1), 0xffff_ffff_ffff_ffff_ffff]`,
+	`[18446744073709551616 == 18446744073709551616.0, 18446744073709551616 < 1, ~18446744073709551616, 18446744073709551617 & 7]`,
+	`let h = {18446744073709551616: 1}; h[9223372036854775808 * 2] += 1; h[18446744073709551616]`,
+	`[int("99999999999999999999"), float(18446744073709551616), floor(1e20), [1][18446744073709551616]]`,
+	`[18446744073709551616 << 1, 18446744073709551616 >> 2, -18446744073709551616 >> 100]`,
+	`1 << 18446744073709551616`,
+	`18446744073709551616 / 0`,
+	// 取余和幂
+	`[7 % 3, -7 % 3, 7 % -3, -7 % -3, 7.5 % 2, 2 ** 10, 2 ** 3 ** 2, -2 ** 2, 2 ** -2, 2 ** 64, 4 ** 0.5]`,
+	`let isEven = fn(x) { x % 2 == 0 }; let r = []; for (i in [-2, -1, 0, 1, 2]) { r = push(r, isEven(i)) } r`,
+	`let x = 7; x %= 4; x **= 2; x`,
+	`try { 1 % 0 } catch (e) { [e["kind"], e["message"]] }`,
+	`2 ** 100000000`,
+	// 字符串比较 重复和in
+	`["a" == "a", "a" != "a", "a" < "b", "b" > "ab", "A" < "a", "x" == 1]`,
+	`["ab" * 3, 3 * "ab", "ab" * 0, "=" * 2 + ">"]`,
+	`let words = ["go", "monkey"]; ["go" in words, "key" in "monkey", "k" in {"k": 1}, 2.0 in [1, 2], [1] in [[1]]]`,
+	`let count = 0; for (w in ["a", "b", "a"]) { if (w in {"a": true}) { count += 1 } } count`,
+	`1 in "abc"`,
+	`"a" * 18446744073709551616`,
+	// 没有定义的名称在运行时才报告 函数体中可以引用之后定义的函数
+	`try { zz } catch (e) { e["kind"] }`,
+	`try { zz = 1 } catch (e) { e["message"] }`,
+	`let f = fn() { try { return g() } catch (e) { 0 } }; let g = fn() { 1 }; f()`,
+	`let f = fn() { try { return g() } catch (e) { e["message"] } }; let r = f(); let g = fn() { 1 }; [r, f()]`,
+	`let f = fn() { n += 1 }; let n = 1; f(); n`,
+	`missing`,
+	// 函数体中给自己的名称赋值 修改的是外层的绑定
+	`let f = fn() { f = 5 }; f(); f`,
+	`let outer = fn() { let f = fn(n) { if (n > 0) { return f(n - 1) } f = n + 10 }; f(3); f }; outer()`,
+	`let f = fn(n) { if (n > 0) { return f(n - 1) } n }; let g = f; let f = fn(n) { n * 100 }; g(2)`,
+	// 尾调用不增加调用深度
+	`let c = fn(n) { if (n == 0) { return 0 } return c(n - 1) }; c(100000)`,
+	`let sum = fn(n, acc) { if (n == 0) { acc } else { sum(n - 1, acc + n) } }; sum(50000, 0)`,
+	`let even = fn(n) { if (n == 0) { true } else { odd(n - 1) } }; let odd = fn(n) { if (n == 0) { false } else { even(n - 1) } }; [even(30001), odd(30001)]`,
+	`let f = fn(n) { if (n == 0) { return 0 } try { return f(n - 1) } catch (e) { e["message"] } }; f(20000)`,
+}
+
+func TestBackendsAgree(t *testing.T) {
+	for _, input := range corpus {
+		expected := inspect(runEvaluator(input))
+		actual, err := runVM(input)
+		if err != nil {
+			t.Errorf("%s: vm error: %s", input, err)
+			continue
+		}
+		if expected != inspect(actual) {
+			t.Errorf("backends disagree for %q.\neval=%q\nvm  =%q",
+				input, expected, inspect(actual))
+		}
+	}
+}
+
+func runEvaluator(input string) object.Object {
+	return evaluator.Eval(parse(input), object.NewEnvironment())
+}
+
+// 最后一条语句不产生值时返回nil 和求值器一样
+func runVM(input string) (object.Object, error) {
+	program := parse(input)
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		return nil, err
+	}
+	machine := New(comp.Bytecode())
+	if err := machine.Run(); err != nil {
+		return nil, err
+	}
+	if !compiler.EndsWithValue(program) && !machine.Halted() {
+		return nil, nil
+	}
+	return machine.LastPoppedStackElem(), nil
+}
+
+func inspect(obj object.Object) string {
+	if obj == nil {
+		return "<no value>"
+	}
+	return obj.Inspect()
+}
+
+const fibonacciInput = `
+let fibonacci = fn(x) {
+	if (x == 0) { return 0; }
+	if (x == 1) { return 1; }
+	fibonacci(x - 1) + fibonacci(x - 2);
+};
+fibonacci(20);
+`
+
+func BenchmarkFibonacciEvaluator(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		runEvaluator(fibonacciInput)
+	}
+}
+
+func BenchmarkFibonacciVM(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		if _, err := runVM(fibonacciInput); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,28 @@
+package vm
+
+import (
+	"monkey/code"
+	"monkey/object"
+)
+
+// 调用帧 每次函数调用对应一个
+type Frame struct {
+	cl          *object.Closure
+	ip          int    // 当前帧的指令指针
+	basePointer int    // 调用时的栈指针 局部绑定从这里开始存放 返回时恢复
+	function    string // 最初调用的函数名和实参个数 用于错误的调用链 尾调用复用调用帧时不变
+	numArgs     int
+	tailCall    bool // 复用这一帧执行过尾调用 当前的函数是cl
+}
+
+func NewFrame(cl *object.Closure, basePointer int) *Frame {
+	return &Frame{
+		cl:          cl,
+		ip:          -1,
+		basePointer: basePointer,
+	}
+}
+
+func (f *Frame) Instructions() code.Instructions {
+	return f.cl.Fn.Instructions
+}
@@ -0,0 +1,12 @@
+package vm
+
+import "monkey/object"
+
+// for-in循环的迭代器 循环期间留在栈上 由OpIterNext依次取出下一个值
+type iterator struct {
+	items []object.Object
+	index int
+}
+
+func (it *iterator) Type() object.ObjectType { return "ITERATOR" }
+func (it *iterator) Inspect() string         { return "iterator" }
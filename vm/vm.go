@@ -0,0 +1,639 @@
+// 栈式虚拟机 执行编译器生成的字节码
+// 运算语义复用求值器的实现 保证两种后端的结果一致
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"monkey/code"
+	"monkey/compiler"
+	"monkey/evaluator"
+	"monkey/object"
+	"monkey/token"
+)
+
+const StackSize = 2048 // 栈的初始大小 不够时扩容
+const GlobalsSize = 65536
+
+var (
+	True  = evaluator.TRUE
+	False = evaluator.FALSE
+	Null  = evaluator.NULL
+)
+
+// 运行时错误 程序停止执行 错误对象就是程序的结果 和求值器的行为一致
+var errHalted = errors.New("halted")
+
+type VM struct {
+	constants []object.Object
+	names     map[string]int // 全局绑定的名称和下标
+	stack     []object.Object
+	sp        int // 始终指向栈中下一个空闲位置 栈顶是 stack[sp-1]
+	globals   []object.Object
+
+	frames      []*Frame
+	framesIndex int
+	handlers    []handler // 正在执行的try 最后一个是最内层的
+	halted      bool      // 因为运行时错误或者顶层的return停止了执行
+
+	// 这次执行的设置 和求值器的CallStack中的同名设置一样
+	MaxDepth          int  // 最大调用深度 超过时抛出错误
+	CheckedArithmetic bool // 整数运算的结果超出int64范围时抛出错误 而不是提升为大整数
+}
+
+// try的错误处理 出错时恢复调用帧和栈 跳转到catch或者finally
+type handler struct {
+	ip          int // 跳转的位置
+	framesIndex int // OpTry所在的调用帧
+	sp          int
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{
+		Instructions: bytecode.Instructions,
+		Positions:    bytecode.Positions,
+	}
+	mainClosure := &object.Closure{Fn: mainFn}
+	mainFrame := NewFrame(mainClosure, 0)
+	frames := []*Frame{mainFrame}
+	return &VM{
+		constants:   bytecode.Constants,
+		names:       bytecode.Globals,
+		stack:       make([]object.Object, StackSize),
+		sp:          0,
+		globals:     make([]object.Object, GlobalsSize),
+		frames:      frames,
+		framesIndex: 1,
+		MaxDepth:    object.DefaultMaxCallDepth,
+	}
+}
+
+// 使用已有的全局绑定 repl中多次输入之间共享
+func NewWithGlobalsStore(bytecode *compiler.Bytecode, s []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = s
+	return vm
+}
+
+// 最后一个弹出栈的元素 也就是最后一个表达式语句的值
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.stack[vm.sp]
+}
+
+func (vm *VM) Run() error {
+	err := vm.run()
+	if err == errHalted {
+		vm.halted = true
+		return nil
+	}
+	return err
+}
+
+// 是否提前停止了执行 此时LastPoppedStackElem是错误对象或者return的值 即使最后一条语句不产生值
+func (vm *VM) Halted() bool {
+	return vm.halted
+}
+
+func (vm *VM) run() error {
+	var ip int
+	var ins code.Instructions
+	var op code.Opcode
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+		ip = vm.currentFrame().ip
+		ins = vm.currentFrame().Instructions()
+		op = code.Opcode(ins[ip])
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			if err := vm.push(vm.constants[constIndex]); err != nil {
+				return err
+			}
+		case code.OpPop:
+			vm.pop()
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv, code.OpMod, code.OpPow, code.OpIn,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLessThan,
+			code.OpGreaterEqual, code.OpLessEqual,
+			code.OpBitAnd, code.OpBitOr, code.OpBitXor, code.OpShiftLeft, code.OpShiftRight:
+			right := vm.pop()
+			left := vm.pop()
+			if err := vm.pushResult(evaluator.EvalInfix(infixOperators[op], left, right, vm.CheckedArithmetic)); err != nil {
+				return err
+			}
+		case code.OpBang:
+			if err := vm.pushResult(evaluator.EvalPrefix("!", vm.pop(), vm.CheckedArithmetic)); err != nil {
+				return err
+			}
+		case code.OpMinus:
+			if err := vm.pushResult(evaluator.EvalPrefix("-", vm.pop(), vm.CheckedArithmetic)); err != nil {
+				return err
+			}
+		case code.OpBitNot:
+			if err := vm.pushResult(evaluator.EvalPrefix("~", vm.pop(), vm.CheckedArithmetic)); err != nil {
+				return err
+			}
+		case code.OpTrue:
+			if err := vm.push(True); err != nil {
+				return err
+			}
+		case code.OpFalse:
+			if err := vm.push(False); err != nil {
+				return err
+			}
+		case code.OpNull:
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1 // 循环开始时会自增
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			condition := vm.pop()
+			if !evaluator.IsTruthy(condition) {
+				vm.currentFrame().ip = pos - 1
+			}
+		case code.OpJumpIfFalsyOrPop, code.OpJumpIfTruthyOrPop:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			truthy := evaluator.IsTruthy(vm.stack[vm.sp-1])
+			if truthy == (op == code.OpJumpIfTruthyOrPop) {
+				vm.currentFrame().ip = pos - 1 // 结果就是栈顶的值
+			} else {
+				vm.pop()
+			}
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			value := vm.globals[globalIndex]
+			if value == nil { // 定义它的let还没有执行 比如之前出错停止了
+				if err := vm.raise(newError(object.NameError, "identifier not found: %s", vm.globalName(int(globalIndex)))); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := vm.push(value); err != nil {
+				return err
+			}
+		case code.OpAssignGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			if vm.globals[globalIndex] == nil {
+				vm.pop()
+				if err := vm.raise(newError(object.NameError, "identifier not found: %s", vm.globalName(int(globalIndex)))); err != nil {
+					return err
+				}
+				continue
+			}
+			vm.globals[globalIndex] = vm.pop()
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			slot := &vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			if c, ok := (*slot).(*cell); ok {
+				c.value = vm.pop() // 重新定义被捕获的绑定 和求值器一样闭包看到新的值
+			} else {
+				*slot = vm.pop()
+			}
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			frame := vm.currentFrame()
+			if err := vm.push(deref(vm.stack[frame.basePointer+int(localIndex)])); err != nil {
+				return err
+			}
+		case code.OpAssignLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			slot := &vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			if c, ok := (*slot).(*cell); ok {
+				c.value = vm.pop()
+			} else {
+				*slot = vm.pop()
+			}
+		case code.OpCaptureLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			slot := &vm.stack[vm.currentFrame().basePointer+int(localIndex)]
+			if _, ok := (*slot).(*cell); !ok {
+				*slot = &cell{value: *slot} // 第一次被捕获时放入cell 之后都通过cell读写
+			}
+			if err := vm.push(*slot); err != nil {
+				return err
+			}
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			definition := object.Builtins[builtinIndex]
+			if err := vm.push(definition.Builtin); err != nil {
+				return err
+			}
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			currentClosure := vm.currentFrame().cl
+			if err := vm.push(deref(currentClosure.Free[freeIndex])); err != nil {
+				return err
+			}
+		case code.OpAssignFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			free := vm.currentFrame().cl.Free
+			if c, ok := free[freeIndex].(*cell); ok {
+				c.value = vm.pop()
+			} else {
+				free[freeIndex] = vm.pop()
+			}
+		case code.OpCaptureFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			if err := vm.push(vm.currentFrame().cl.Free[freeIndex]); err != nil {
+				return err
+			}
+		case code.OpCurrentClosure:
+			if err := vm.push(vm.currentFrame().cl); err != nil {
+				return err
+			}
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+			if err := vm.push(array); err != nil {
+				return err
+			}
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			hash := vm.buildHash(vm.sp-numElements, vm.sp)
+			vm.sp = vm.sp - numElements
+			if err := vm.pushResult(hash); err != nil {
+				return err
+			}
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			if err := vm.pushResult(evaluator.EvalIndex(left, index)); err != nil {
+				return err
+			}
+		case code.OpSetIndex:
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			if err := vm.pushResult(evaluator.EvalSetIndex(left, index, value)); err != nil {
+				return err
+			}
+		case code.OpDupTwo:
+			if err := vm.push(vm.stack[vm.sp-2]); err != nil {
+				return err
+			}
+			if err := vm.push(vm.stack[vm.sp-2]); err != nil {
+				return err
+			}
+		case code.OpIter:
+			items, errObj := evaluator.IterableItems(vm.pop())
+			if errObj != nil {
+				if err := vm.raise(errObj); err != nil {
+					return err
+				}
+				continue // 错误被catch 从catch继续执行
+			}
+			if err := vm.push(&iterator{items: items}); err != nil {
+				return err
+			}
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			it := vm.stack[vm.sp-1].(*iterator)
+			if it.index >= len(it.items) {
+				vm.pop()
+				vm.currentFrame().ip = pos - 1 // 循环结束
+			} else {
+				if err := vm.push(it.items[it.index]); err != nil {
+					return err
+				}
+				it.index++
+			}
+		case code.OpTry:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+			vm.handlers = append(vm.handlers, handler{ip: pos, framesIndex: vm.framesIndex, sp: vm.sp})
+		case code.OpEndTry:
+			vm.handlers = vm.handlers[:len(vm.handlers)-1]
+		case code.OpThrow:
+			if err := vm.raise(evaluator.ThrowValue(vm.pop())); err != nil {
+				return err
+			}
+		case code.OpCatch:
+			vm.stack[vm.sp-1] = evaluator.ErrorHash(vm.stack[vm.sp-1].(*object.Error))
+		case code.OpEndFinally:
+			if errObj, ok := vm.pop().(*object.Error); ok {
+				if err := vm.raise(errObj); err != nil { // 没有被catch的错误 执行完finally之后继续抛出
+					return err
+				}
+			}
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip += 1
+			if err := vm.executeCall(int(numArgs)); err != nil {
+				return err
+			}
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.framesIndex == 1 {
+				return vm.halt(returnValue) // 顶层的return 停止执行程序
+			}
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1 // 同时弹出被调用的函数
+			if err := vm.push(returnValue); err != nil {
+				return err
+			}
+		case code.OpReturn:
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			if err := vm.push(Null); err != nil {
+				return err
+			}
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+			if err := vm.pushClosure(int(constIndex), int(numFree)); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unknown opcode %d", op)
+		}
+	}
+	return nil
+}
+
+// 操作码对应的中缀运算符
+var infixOperators = map[code.Opcode]string{
+	code.OpAdd:          "+",
+	code.OpSub:          "-",
+	code.OpMul:          "*",
+	code.OpDiv:          "/",
+	code.OpMod:          "%",
+	code.OpPow:          "**",
+	code.OpGreaterThan:  ">",
+	code.OpLessThan:     "<",
+	code.OpGreaterEqual: ">=",
+	code.OpLessEqual:    "<=",
+	code.OpEqual:        "==",
+	code.OpNotEqual:     "!=",
+	code.OpBitAnd:       "&",
+	code.OpBitOr:        "|",
+	code.OpBitXor:       "^",
+	code.OpShiftLeft:    "<<",
+	code.OpShiftRight:   ">>",
+	code.OpIn:           "in",
+}
+
+func (vm *VM) executeCall(numArgs int) error {
+	callee := vm.stack[vm.sp-1-numArgs] // 函数在参数的下面
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return vm.raise(newError(object.TypeError, "not a function: %s", callee.Type()))
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) error {
+	pos := vm.currentPos()
+	var frame *Frame
+	if vm.isTailCall() {
+		frame = vm.reuseFrame(cl, numArgs)
+	} else {
+		if vm.framesIndex-1 >= vm.MaxDepth { // 第一个调用帧是顶层程序 不算调用深度
+			return vm.raise(newError(object.RecursionError, "maximum call depth %d exceeded", vm.MaxDepth))
+		}
+		frame = NewFrame(cl, vm.sp-numArgs)
+		frame.function, frame.numArgs = cl.Fn.Name, numArgs
+		vm.pushFrame(frame)
+	}
+	if numArgs != cl.Fn.NumParameters {
+		err := newError(object.ArityError, "wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+		err.Pos = pos
+		return vm.raise(err) // 和求值器一样 被调用的函数也在调用链中
+	}
+	// 参数已经在栈上了 是前几个局部绑定 再为其余局部绑定预留空间
+	vm.sp = frame.basePointer + cl.Fn.NumLocals
+	vm.growStack(vm.sp)
+	// 清空上一次调用留下的值 否则定义局部绑定时可能写入别的闭包捕获的cell
+	for i := vm.sp - cl.Fn.NumLocals + numArgs; i < vm.sp; i++ {
+		vm.stack[i] = nil
+	}
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+	if result == nil {
+		result = Null
+	}
+	return vm.pushResult(result)
+}
+
+func (vm *VM) pushClosure(constIndex int, numFree int) error {
+	constant := vm.constants[constIndex]
+	function, ok := constant.(*object.CompiledFunction)
+	if !ok {
+		return fmt.Errorf("not a function: %+v", constant)
+	}
+	free := make([]object.Object, numFree)
+	for i := 0; i < numFree; i++ {
+		free[i] = vm.stack[vm.sp-numFree+i]
+	}
+	vm.sp = vm.sp - numFree
+	closure := &object.Closure{Fn: function, Free: free}
+	return vm.push(closure)
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+	for i := startIndex; i < endIndex; i++ {
+		elements[i-startIndex] = vm.stack[i]
+	}
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) object.Object {
+	hashedPairs := make(map[object.HashKey]object.HashPair)
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return newError(object.TypeError, "unusable as hash key: %s", key.Type())
+		}
+		hashedPairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+	return &object.Hash{Pairs: hashedPairs}
+}
+
+// 当前指令对应的源码位置
+func (vm *VM) currentPos() token.Position {
+	return framePos(vm.currentFrame())
+}
+
+// 调用帧正在执行的指令对应的源码位置 对调用方来说就是调用的位置
+func framePos(frame *Frame) token.Position {
+	positions := frame.cl.Fn.Positions
+	if frame.ip < 0 || frame.ip >= len(positions) {
+		return token.Position{}
+	}
+	return positions[frame.ip]
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) {
+	if vm.framesIndex < len(vm.frames) {
+		vm.frames[vm.framesIndex] = f
+	} else {
+		vm.frames = append(vm.frames, f)
+	}
+	vm.framesIndex++
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) push(o object.Object) error {
+	vm.growStack(vm.sp + 1)
+	vm.stack[vm.sp] = o
+	vm.sp++
+	return nil
+}
+
+// 保证栈中至少有size个位置 调用深度由MaxDepth限制 栈不会无限增长
+func (vm *VM) growStack(size int) {
+	if size <= len(vm.stack) {
+		return
+	}
+	newSize := 2 * len(vm.stack)
+	if newSize < size {
+		newSize = size
+	}
+	stack := make([]object.Object, newSize)
+	copy(stack, vm.stack)
+	vm.stack = stack
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+// 压入运算结果 错误对象会停止执行
+func (vm *VM) pushResult(result object.Object) error {
+	if errObj, ok := result.(*object.Error); ok {
+		return vm.raise(errObj)
+	}
+	return vm.push(result)
+}
+
+// 抛出错误 交给最内层的try处理 没有try时停止执行
+// 恢复到OpTry时的调用帧和栈 压入错误 从catch或者finally继续执行
+func (vm *VM) raise(err *object.Error) error {
+	if !err.Pos.IsValid() {
+		err.Pos = vm.currentPos() // 记录出错指令对应的源码位置
+	}
+	if len(vm.handlers) == 0 {
+		vm.unwind(err, 1)
+		return vm.halt(err)
+	}
+	h := vm.handlers[len(vm.handlers)-1]
+	vm.handlers = vm.handlers[:len(vm.handlers)-1]
+	vm.unwind(err, h.framesIndex)
+	vm.sp = h.sp
+	vm.currentFrame().ip = h.ip - 1 // 循环开始时会自增
+	return vm.push(err)
+}
+
+// 弹出调用帧直到只剩framesIndex个 把弹出的调用添加到错误的调用链中 最内层的调用在前
+// 调用的位置是调用方正在执行的OpCall 尾调用复用了调用帧
+// 和求值器一样 记录的是最初调用的函数和位置 并标记执行过尾调用
+func (vm *VM) unwind(err *object.Error, framesIndex int) {
+	for vm.framesIndex > framesIndex {
+		callee := vm.popFrame()
+		err.Trace = append(err.Trace, object.Frame{
+			Function: callee.function,
+			Pos:      vm.currentPos(),
+			NumArgs:  callee.numArgs,
+			Tail:     callee.tailCall,
+			TailCall: callee.cl.Fn.Name,
+		})
+	}
+}
+
+// 正在执行的OpCall是否在尾部位置 也就是之后经过跳转直接返回
+// 顶层程序的帧不能复用 当前函数中还有没有结束的try时 返回之前要经过OpEndTry 也不是尾调用
+func (vm *VM) isTailCall() bool {
+	if vm.framesIndex == 1 {
+		return false
+	}
+	if n := len(vm.handlers); n > 0 && vm.handlers[n-1].framesIndex == vm.framesIndex {
+		return false
+	}
+	frame := vm.currentFrame()
+	ins := frame.Instructions()
+	ip := frame.ip + 1
+	for ip < len(ins) && code.Opcode(ins[ip]) == code.OpJump {
+		ip = int(code.ReadUint16(ins[ip+1:]))
+	}
+	return ip < len(ins) && code.Opcode(ins[ip]) == code.OpReturnValue
+}
+
+// 尾调用复用当前的调用帧 被调用的函数和实参移到当前函数和实参的位置 调用深度不变
+// 和求值器一样 递归的尾调用只占用固定的栈空间
+func (vm *VM) reuseFrame(cl *object.Closure, numArgs int) *Frame {
+	frame := vm.currentFrame()
+	copy(vm.stack[frame.basePointer-1:], vm.stack[vm.sp-1-numArgs:vm.sp])
+	vm.sp = frame.basePointer + numArgs
+	frame.cl = cl
+	frame.ip = -1
+	frame.tailCall = true
+	return frame
+}
+
+// 停止执行 result作为程序的结果 通过LastPoppedStackElem获取
+func (vm *VM) halt(result object.Object) error {
+	vm.growStack(vm.sp + 1)
+	if err, ok := result.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = vm.currentPos() // 记录出错指令对应的源码位置
+	}
+	vm.stack[vm.sp] = result
+	return errHalted
+}
+
+// 全局绑定的名称
+func (vm *VM) globalName(index int) string {
+	for name, i := range vm.names {
+		if i == index {
+			return name
+		}
+	}
+	return fmt.Sprintf("global %d", index)
+}
+
+func newError(kind, format string, a ...any) *object.Error {
+	return &object.Error{Message: fmt.Sprintf(format, a...), Kind: kind}
+}
@@ -0,0 +1,494 @@
+package vm
+
+import (
+	"math/big"
+	"monkey/ast"
+	"monkey/compiler"
+	"monkey/lexer"
+	"monkey/object"
+	"monkey/parser"
+	"testing"
+)
+
+type vmTestCase struct {
+	input    string
+	expected interface{}
+}
+
+func TestIntegerArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"1", 1},
+		{"1 + 2", 3},
+		{"4 / 2", 2},
+		{"50 / 2 * 2 + 10 - 5", 55},
+		{"5 * (2 + 10)", 60},
+		{"-50 + 100 + -50", 0},
+		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", 4},
+		{"let x = 10; x %= 4; x **= 3; x", 8},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBigIntArithmetic(t *testing.T) {
+	two64, _ := new(big.Int).SetString("18446744073709551616", 10)
+	tests := []vmTestCase{
+		{"18446744073709551616", two64},
+		{"9223372036854775807 * 2 + 2", two64},
+		{"18446744073709551616 / 2 - 1", 9223372036854775807},
+		{"-9223372036854775808", -9223372036854775808},
+		{"let f = fn(n, acc) { if (n == 0) { acc } else { f(n - 1, acc * 2) } }; f(64, 1)", two64},
+		{"18446744073709551616 > 9223372036854775807", true},
+		{`{18446744073709551616: "big"}[9223372036854775808 * 2]`, "big"},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestStringOperators(t *testing.T) {
+	tests := []vmTestCase{
+		{`"a" == "a"`, true},
+		{`"a" != "b"`, true},
+		{`"abc" < "abd"`, true},
+		{`"b" > "abc"`, true},
+		{`"ab" * 3`, "ababab"},
+		{`2 * "-"`, "--"},
+		{`"ab" * -1`, ""},
+		{`"ell" in "hello"`, true},
+		{`3 in [1, 2]`, false},
+		{`let h = {"k": 1}; ["k" in h, "x" in h]`, []interface{}{true, false}},
+		{`1 in 1`, &object.Error{Message: "unknown operator: INTEGER in INTEGER"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestBooleanExpressions(t *testing.T) {
+	tests := []vmTestCase{
+		{"true", true},
+		{"1 < 2", true},
+		{"1 > 2", false},
+		{"1 == 1", true},
+		{"1 != 2", true},
+		{"true != false", true},
+		{"(1 < 2) == true", true},
+		{"!5", false},
+		{"!!5", true},
+		{"!(if (false) { 5; })", true},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestConditionals(t *testing.T) {
+	tests := []vmTestCase{
+		{"if (true) { 10 }", 10},
+		{"if (1 > 2) { 10 } else { 20 }", 20},
+		{"if (1 > 2) { 10 }", Null},
+		{"if ((if (false) { 10 })) { 10 } else { 20 }", 20},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestGlobalLetStatements(t *testing.T) {
+	tests := []vmTestCase{
+		{"let one = 1; one", 1},
+		{"let one = 1; let two = one + one; one + two", 3},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCallingFunctions(t *testing.T) {
+	tests := []vmTestCase{
+		{"let fivePlusTen = fn() { 5 + 10; }; fivePlusTen();", 15},
+		{"let earlyExit = fn() { return 99; 100; }; earlyExit();", 99},
+		{"let noReturn = fn() { }; noReturn();", Null},
+		{"let sum = fn(a, b) { let c = a + b; c; }; sum(1, 2);", 3},
+		{
+			`
+			let globalNum = 10;
+			let sum = fn(a, b) { let c = a + b; c + globalNum; };
+			let outer = fn() { sum(1, 2) + sum(3, 4) + globalNum; };
+			outer() + globalNum;
+			`,
+			50,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestClosures(t *testing.T) {
+	tests := []vmTestCase{
+		{
+			`
+			let newAdder = fn(a, b) {
+				let c = a + b;
+				fn(d) { c + d };
+			};
+			let adder = newAdder(1, 2);
+			adder(8);
+			`,
+			11,
+		},
+		{
+			`
+			let wrapper = fn() {
+				let countDown = fn(x) {
+					if (x == 0) { return 0; } else { countDown(x - 1); }
+				};
+				countDown(1);
+			};
+			wrapper();
+			`,
+			0,
+		},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []vmTestCase{
+		{"let x = 1; x = 2; x", 2},
+		{"let x = 1; let y = 1; x = y = 5; x + y", 10},
+		{"let x = 10; x -= 3", 7},
+		{"fn() { let x = 1; x += 2; x }()", 3},
+		{"fn(x) { x *= 3 }(4)", 12},
+		// 被捕获的绑定通过cell共享 赋值对定义它的函数和所有闭包都可见
+		{"let count = 0; let inc = fn() { count += 1 }; inc(); inc(); count", 2},
+		{
+			`
+			let counter = fn() {
+				let n = 0;
+				fn() { n = n + 1; n };
+			};
+			let a = counter();
+			let b = counter();
+			a(); a(); b();
+			[a(), b()]
+			`,
+			[]int{3, 2},
+		},
+		{
+			`
+			let pair = fn() {
+				let n = 0;
+				let get = fn() { n };
+				let set = fn(v) { n = v };
+				set(5);
+				[get(), n]
+			};
+			pair()
+			`,
+			[]int{5, 5},
+		},
+		{
+			`
+			let outer = fn(n) {
+				let middle = fn() { fn() { n += 10 } };
+				middle()();
+				n
+			};
+			outer(1)
+			`,
+			11,
+		},
+		{"let a = [1, 2, 3]; a[0] = 7; a[2] += 1; a", []int{7, 2, 4}},
+		{`let h = {}; h["k"] = 1; h["k"] += 1; h["k"]`, 2},
+		{"let a = [1]; let i = 0; let next = fn() { i += 1; 0 }; a[next()] += 5; [a[0], i]", []int{6, 1}},
+		{"let a = [1]; a[3] = 1", &object.Error{Message: "index out of range: 3, array length is 1"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestLoops(t *testing.T) {
+	tests := []vmTestCase{
+		{"let i = 0; while (i < 10) { i += 1 }; i", 10},
+		{"let sum = 0; for (x in [1, 2, 3]) { sum += x }; sum", 6},
+		{"let sum = 0; for (x in [1, 2, 3, 4]) { if (x == 2) { continue }; if (x == 4) { break }; sum += x }; sum", 4},
+		{`let out = ""; for (k in {"b": 1, "a": 2}) { out += k }; out`, "ab"},
+		{`let out = []; for (c in "中a") { out = push(out, c) }; out`, []string{"中", "a"}},
+		{
+			`
+			let f = fn(n) {
+				let count = 0;
+				for (i in [1, 2, 3]) {
+					let j = 0;
+					while (true) {
+						j += 1;
+						if (j > i) { break }
+						count += n;
+					}
+				}
+				count
+			};
+			f(2)
+			`,
+			12,
+		},
+		// 循环中的return 栈上的迭代器随调用帧一起丢弃
+		{"let find = fn(arr) { for (x in arr) { for (y in arr) { if (x + y == 5) { return [x, y] } } } }; find([1, 2, 3, 4])", []int{1, 4}},
+		// 循环很多次栈也不会增长
+		{"let i = 0; for (x in [1, 2, 3]) { while (i < 5000) { i += 1; if (i == 100) { continue } } }; i", 5000},
+		{"for (x in 1) { }", &object.Error{Message: "cannot iterate over INTEGER"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestRuntimeErrors(t *testing.T) {
+	tests := []vmTestCase{
+		{"5 + true; 5;", &object.Error{Message: "type mismatch: INTEGER + BOOLEAN"}},
+		{"-true", &object.Error{Message: "unknown operator: -BOOLEAN"}},
+		{`len(1)`, &object.Error{Message: "argument to `len` not supported, got INTEGER"}},
+		{"1(2)", &object.Error{Message: "not a function: INTEGER"}},
+		{"fn(a) { a }()", &object.Error{Message: "wrong number of arguments: want=1, got=0"}},
+		{"1 / 0", &object.Error{Message: "division by zero"}},
+		{"1 % 0", &object.Error{Message: "modulo by zero"}},
+		{"0 ** -1", &object.Error{Message: "zero to a negative power: 0 ** -1"}},
+		{"let x = 1; x /= 0; x", &object.Error{Message: "division by zero"}},
+		{`{"name": "Monkey"}[fn(x) { x }];`, &object.Error{Message: "unusable as hash key: CLOSURE"}},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestCheckedArithmetic(t *testing.T) {
+	tests := []vmTestCase{
+		{"9223372036854775807 + 1", &object.Error{Message: "integer overflow: 9223372036854775807 + 1"}},
+		{"let f = fn(x) { x * x }; f(4294967296)", &object.Error{Message: "integer overflow: 4294967296 * 4294967296"}},
+		{"let x = -9223372036854775807; x -= 2; x", &object.Error{Message: "integer overflow: -9223372036854775807 - 2"}},
+		{"try { 9223372036854775807 + 1 } catch (e) { e[\"kind\"] }", "OverflowError"},
+		{"9223372036854775806 + 1", 9223372036854775807},
+		{"-(-9223372036854775807 - 1)", &object.Error{Message: "integer overflow: -(-9223372036854775808)"}},
+	}
+
+	runVmTestsWith(t, tests, func(vm *VM) { vm.CheckedArithmetic = true })
+	// 设置只影响这个虚拟机
+	two63, _ := new(big.Int).SetString("9223372036854775808", 10)
+	runVmTests(t, []vmTestCase{{"9223372036854775807 + 1", two63}})
+}
+
+func TestCallDepthLimit(t *testing.T) {
+	tests := []vmTestCase{
+		{"let f = fn(n) { if (n == 0) { 0 } else { 1 + f(n - 1) } }; f(5000)", 5000},
+		{"let f = fn(n) { f(n + 1); 0 }; f(0)", &object.Error{Message: "maximum call depth 10000 exceeded"}},
+		{`let f = fn(n) { f(n + 1); 0 }; try { f(0) } catch (e) { [e["kind"], e["message"]] }`,
+			[]string{"RecursionError", "maximum call depth 10000 exceeded"}},
+	}
+	runVmTests(t, tests)
+
+	program := parse("let f = fn(n) { if (n == 0) { 0 } else { let r = f(n - 1); r } }; [f(50), f(51)]")
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	machine := New(comp.Bytecode())
+	machine.MaxDepth = 51
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	result := machine.LastPoppedStackElem()
+	if result.Inspect() != "ERROR: 1:51: maximum call depth 51 exceeded" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+
+	// 尾调用复用调用帧 不受深度限制
+	program = parse("let c = fn(n) { if (n == 0) { return 0 } return c(n - 1) }; c(1000)")
+	comp = compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %s", err)
+	}
+	machine = New(comp.Bytecode())
+	machine.MaxDepth = 51
+	if err := machine.Run(); err != nil {
+		t.Fatalf("vm error: %s", err)
+	}
+	if result := machine.LastPoppedStackElem(); result.Inspect() != "0" {
+		t.Errorf("wrong result. got=%s", result.Inspect())
+	}
+}
+
+func TestTopLevelReturn(t *testing.T) {
+	tests := []vmTestCase{
+		{"return 10; 9;", 10},
+		{"9; if (true) { return 2 * 5; } 9;", 10},
+	}
+
+	runVmTests(t, tests)
+}
+
+func TestTryCatch(t *testing.T) {
+	tests := []vmTestCase{
+		{`try { 1 } catch (e) { 2 }`, 1},
+		{`try { throw "bad"; 1 } catch (e) { e["message"] }`, "bad"},
+		{`try { throw "bad" } catch (e) { [e["kind"], e["trace"]] }`, []interface{}{"Error", []int{}}},
+		{`try { throw {"message": "m", "kind": "ValueError"} } catch (e) { e["kind"] + ": " + e["message"] }`, "ValueError: m"},
+		{`try { 1 + true } catch (e) { e["message"] }`, "type mismatch: INTEGER + BOOLEAN"},
+		{`try { len(1) } catch (e) { e["message"] }`, "argument to `len` not supported, got INTEGER"},
+		{`try { 1(2) } catch (e) { e["message"] }`, "not a function: INTEGER"},
+		{`try { fn(x) { x }() } catch (e) { e["message"] }`, "wrong number of arguments: want=1, got=0"},
+		{`try { for (x in 1) { } } catch (e) { e["message"] }`, "cannot iterate over INTEGER"},
+		// 错误跨越调用帧 恢复栈之后继续执行
+		{`let f = fn(n) { if (n == 0) { throw "bottom" } 1 + f(n - 1) }; [try { f(5) } catch (e) { e["message"] }, 2]`, []interface{}{"bottom", 2}},
+		{`let f = fn() { let x = try { throw "a" } catch (e) { 5 }; x * 2 }; f() + 1`, 11},
+		{`try { try { throw "a" } catch (e) { throw e } } catch (e) { e["message"] }`, "a"},
+		{`try { try { throw "a" } catch (e) { throw "b" } } catch (e) { e["message"] }`, "b"},
+		{`try { throw "x" } catch (e) { 1 }; e["message"]`, "x"},
+		// 调用链中有每一层调用 最内层的在前
+		{`let f = fn(n) { if (n == 0) { throw "x" } 1 + f(n - 1) }; try { f(50) } catch (e) { len(e["trace"]) }`, 51},
+		{`let f = fn(n) { if (n == 0) { throw "x" } 1 + f(n - 1) }; try { f(2) } catch (e) { e["trace"] }`,
+			[]string{"f(1 arg) called at 1:48", "f(1 arg) called at 1:48", "f(1 arg) called at 1:66"}},
+		// 尾调用复用调用帧 记录最初调用的函数和位置
+		{`let g = fn() { 1 / 0 }; let h = fn() { g() }; let f = fn() { let x = h(); x }; try { f() } catch (e) { e["trace"] }`,
+			[]string{"h(0 args) (tail call to g) called at 1:71", "f(0 args) called at 1:87"}},
+		{`throw "boom"`, &object.Error{Message: "boom"}},
+		{`try { throw "a" } finally { 1 }`, &object.Error{Message: "a"}},
+	}
+	runVmTests(t, tests)
+}
+
+func TestFinally(t *testing.T) {
+	tests := []vmTestCase{
+		{`let log = []; try { log = push(log, 1) } finally { log = push(log, 2) }; log`, []int{1, 2}},
+		{`let log = []; try { throw "x" } catch (e) { log = push(log, 1) } finally { log = push(log, 2) }; log`, []int{1, 2}},
+		{`try { 1 } catch (e) { 2 } finally { 3 }`, 1},
+		{`[1, try { 2 } finally { 3 }, 4]`, []int{1, 2, 4}},
+		{`let n = 0; let r = try { try { throw "x" } finally { n = 1 } } catch (e) { e["message"] }; [r, n]`, []interface{}{"x", 1}},
+		{`let n = 0; let r = try { try { throw "x" } catch (e) { throw "y" } finally { n = 1 } } catch (e) { e["message"] }; [r, n]`, []interface{}{"y", 1}},
+		{`let f = fn() { try { return 1 } finally { return 2 } }; f()`, 2},
+		{`let f = fn() { try { throw "x" } finally { return 2 } }; f()`, 2},
+		// finally中的let不影响try块中return之后的代码
+		{`let x = 1; let f = fn(c) { try { if (c) { return 0 } x } finally { let x = 2 } }; f(false)`, 1},
+	}
+	runVmTests(t, tests)
+}
+
+func TestTryControlFlow(t *testing.T) {
+	tests := []vmTestCase{
+		{`let f = fn() { try { return 1; 2 } catch (e) { 3 }; 4 }; f()`, 1},
+		{`let f = fn() { try { throw "x" } catch (e) { return 3 }; 4 }; f()`, 3},
+		{`let n = 0; let f = fn() { try { return 1 } finally { n = 5 } }; [f(), n]`, []int{1, 5}},
+		{`let out = []; for (i in [1, 2, 3, 4]) { try { if (i == 2) { continue } if (i == 4) { break } out = push(out, i) } finally { out = push(out, 0) } } out`, []int{1, 0, 0, 3, 0, 0}},
+		{`let out = []; for (i in [1, 2, 3]) { try { if (i == 2) { throw "skip" } out = push(out, i) } catch (e) { continue } } out`, []int{1, 3}},
+		{`let out = []; for (i in [1, 2]) { try { for (j in [1, 2]) { try { if (j == 2) { break } out = push(out, j) } finally { out = push(out, 9) } } } finally { out = push(out, 0) } } out`, []int{1, 9, 9, 0, 1, 9, 9, 0}},
+		{`let g = fn() { throw "g" }; let f = fn() { try { return g() } catch (e) { return e["message"] } }; f()`, "g"},
+		{`let log = []; let g = fn() { log = push(log, "g"); 1 }; let f = fn() { try { return g() } finally { log = push(log, "finally") } }; f(); log`, []string{"g", "finally"}},
+		// 顶层的return也执行finally
+		{`let n = 0; try { return 1 } finally { n = 2 }`, 1},
+	}
+	runVmTests(t, tests)
+}
+
+func runVmTests(t *testing.T, tests []vmTestCase) {
+	t.Helper()
+	runVmTestsWith(t, tests, func(vm *VM) {})
+}
+
+// 运行之前用configure修改虚拟机的设置
+func runVmTestsWith(t *testing.T, tests []vmTestCase, configure func(vm *VM)) {
+	t.Helper()
+
+	for _, tt := range tests {
+		program := parse(tt.input)
+
+		comp := compiler.New()
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %s", err)
+		}
+
+		vm := New(comp.Bytecode())
+		configure(vm)
+		if err := vm.Run(); err != nil {
+			t.Fatalf("vm error: %s", err)
+		}
+
+		if len(vm.handlers) != 0 {
+			t.Errorf("%s: %d try handlers left after run", tt.input, len(vm.handlers))
+		}
+
+		stackElem := vm.LastPoppedStackElem()
+		testExpectedObject(t, tt.input, tt.expected, stackElem)
+	}
+}
+
+func testExpectedObject(t *testing.T, input string, expected interface{}, actual object.Object) {
+	t.Helper()
+
+	switch expected := expected.(type) {
+	case int:
+		result, ok := actual.(*object.Integer)
+		if !ok || result.Value != int64(expected) {
+			t.Errorf("%s: object is not Integer %d. got=%T (%+v)", input, expected, actual, actual)
+		}
+	case *big.Int:
+		result, ok := actual.(*object.BigInt)
+		if !ok || result.Value.Cmp(expected) != 0 {
+			t.Errorf("%s: object is not BigInt %s. got=%T (%+v)", input, expected, actual, actual)
+		}
+	case bool:
+		result, ok := actual.(*object.Boolean)
+		if !ok || result.Value != expected {
+			t.Errorf("%s: object is not Boolean %t. got=%T (%+v)", input, expected, actual, actual)
+		}
+	case string:
+		result, ok := actual.(*object.String)
+		if !ok || result.Value != expected {
+			t.Errorf("%s: object is not String %q. got=%T (%+v)", input, expected, actual, actual)
+		}
+	case []int:
+		array, ok := actual.(*object.Array)
+		if !ok || len(array.Elements) != len(expected) {
+			t.Errorf("%s: object is not Array of %d elements. got=%T (%+v)", input, len(expected), actual, actual)
+			return
+		}
+		for i, el := range expected {
+			testExpectedObject(t, input, el, array.Elements[i])
+		}
+	case []interface{}:
+		array, ok := actual.(*object.Array)
+		if !ok || len(array.Elements) != len(expected) {
+			t.Errorf("%s: object is not Array of %d elements. got=%T (%+v)", input, len(expected), actual, actual)
+			return
+		}
+		for i, el := range expected {
+			testExpectedObject(t, input, el, array.Elements[i])
+		}
+	case []string:
+		array, ok := actual.(*object.Array)
+		if !ok || len(array.Elements) != len(expected) {
+			t.Errorf("%s: object is not Array of %d elements. got=%T (%+v)", input, len(expected), actual, actual)
+			return
+		}
+		for i, el := range expected {
+			testExpectedObject(t, input, el, array.Elements[i])
+		}
+	case *object.Null:
+		if actual != Null {
+			t.Errorf("%s: object is not Null: %T (%+v)", input, actual, actual)
+		}
+	case *object.Error:
+		errObj, ok := actual.(*object.Error)
+		if !ok {
+			t.Errorf("%s: object is not Error: %T (%+v)", input, actual, actual)
+			return
+		}
+		if errObj.Message != expected.Message {
+			t.Errorf("%s: wrong error message. expected=%q, got=%q", input, expected.Message, errObj.Message)
+		}
+	}
+}
+
+func parse(input string) *ast.Program {
+	l := lexer.New(input)
+	p := parser.New(l)
+	return p.ParseProgram()
+}
@@ -0,0 +1,33 @@
+// 字符在终端中显示的宽度 行编辑器和诊断信息共用
+package width
+
+// 字符在终端中显示的宽度 中日韩等全角字符占两列 其他字符占一列
+// 用于在终端中把错误标记 ^ 和源码的列对齐 以及移动光标
+func Rune(r rune) int {
+	if isWide(r) {
+		return 2
+	}
+	return 1
+}
+
+// 字符串在终端中显示的宽度
+func String(s string) int {
+	w := 0
+	for _, r := range s {
+		w += Rune(r)
+	}
+	return w
+}
+
+func isWide(r rune) bool {
+	return r >= 0x1100 && (r <= 0x115f || // 谚文字母
+		r >= 0x2e80 && r <= 0xa4cf && r != 0x303f || // 中日韩部首 汉字 假名等
+		r >= 0xac00 && r <= 0xd7a3 || // 谚文音节
+		r >= 0xf900 && r <= 0xfaff || // 兼容汉字
+		r >= 0xfe30 && r <= 0xfe4f || // 兼容标点
+		r >= 0xff00 && r <= 0xff60 || // 全角字符
+		r >= 0xffe0 && r <= 0xffe6 ||
+		r >= 0x1f300 && r <= 0x1f64f || // 表情
+		r >= 0x1f900 && r <= 0x1f9ff ||
+		r >= 0x20000 && r <= 0x3fffd)
+}
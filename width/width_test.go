@@ -0,0 +1,25 @@
+package width
+
+import "testing"
+
+func TestString(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected int
+	}{
+		{"", 0},
+		{"let x", 5},
+		{"中文", 4},
+		{"a中b", 4},
+		{"ｘ", 2}, // 全角字母
+		{"한글", 4},
+		{"😀", 2},
+		{"é", 1},
+	}
+
+	for _, tt := range tests {
+		if got := String(tt.input); got != tt.expected {
+			t.Errorf("String(%q) wrong. want=%d, got=%d", tt.input, tt.expected, got)
+		}
+	}
+}